@@ -3,7 +3,9 @@ package gowalletsafrica
 import (
 	"errors"
 	"fmt"
+	"github.com/jcobhams/gowalletsafrica/phone"
 	"net/http"
+	"strings"
 )
 
 //GetProviders - returns a list of Network Providers
@@ -41,3 +43,19 @@ func (a *airtime) GetProviders() (AirtimeProviders, error) {
 
 	return providers, nil
 }
+
+//ForPhoneNumber picks the provider for the network phoneNumber belongs to, so top-ups do not need the
+//user to choose one. Numbers without a calling code are read as Nigerian.
+func (p AirtimeProviders) ForPhoneNumber(phoneNumber string) (AirtimeProvider, error) {
+	network, err := phone.DetectNetwork(phoneNumber)
+	if err != nil {
+		return AirtimeProvider{}, err
+	}
+
+	for _, provider := range p {
+		if strings.EqualFold(provider.Code, string(network)) {
+			return provider, nil
+		}
+	}
+	return AirtimeProvider{}, errors.New(fmt.Sprintf("no airtime provider for the %v network", network))
+}
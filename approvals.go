@@ -0,0 +1,724 @@
+package gowalletsafrica
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ApprovalPending   ApprovalStatus = "pending"
+	ApprovalApproved  ApprovalStatus = "approved" //Has every approval it needs and waits to be executed
+	ApprovalRejected  ApprovalStatus = "rejected"
+	ApprovalExpired   ApprovalStatus = "expired"
+	ApprovalExecuting ApprovalStatus = "executing"
+	ApprovalExecuted  ApprovalStatus = "executed"
+	ApprovalFailed    ApprovalStatus = "failed"
+	ApprovalUnknown   ApprovalStatus = "unknown" //Sent without a definite answer, e.g. on a timeout; check with the bank and Resolve it
+
+	ApprovalKindPayout ApprovalKind = "payout"
+	ApprovalKindCredit ApprovalKind = "credit"
+
+	AuditCreated   AuditAction = "created"
+	AuditApproved  AuditAction = "approved"
+	AuditRejected  AuditAction = "rejected"
+	AuditExpired   AuditAction = "expired"
+	AuditExecuting AuditAction = "executing"
+	AuditExecuted  AuditAction = "executed"
+	AuditFailed    AuditAction = "failed"
+	AuditUnknown   AuditAction = "unknown"
+	AuditResolved  AuditAction = "resolved"
+
+	DefaultApprovalTTL time.Duration = 24 * time.Hour
+
+	//AuditActorSystem is the actor of audit entries made by the workflow itself, e.g. expiries
+	AuditActorSystem string = "system"
+)
+
+type (
+	ApprovalStatus string
+	ApprovalKind   string
+	AuditAction    string
+
+	//ApprovalThreshold requires Approvals approvals for amounts above Above
+	ApprovalThreshold struct {
+		Above     float64
+		Approvals int
+	}
+
+	//ApprovalPolicy decides how many approvals a request needs. The highest matching threshold wins;
+	//amounts below every threshold need no approval but still go through the workflow and its audit trail.
+	ApprovalPolicy struct {
+		Thresholds []ApprovalThreshold
+		TTL        time.Duration //Time to get approved and executed, defaults to DefaultApprovalTTL
+	}
+
+	Approval struct {
+		Approver string    `json:"approver"`
+		At       time.Time `json:"at"`
+		Comment  string    `json:"comment,omitempty"`
+	}
+
+	//ApprovalRequest is a payout or credit waiting for, or done with, approval. ID is the transaction reference.
+	ApprovalRequest struct {
+		ID                string             `json:"id"`
+		Kind              ApprovalKind       `json:"kind"`
+		Payout            *PayoutInstruction `json:"payout,omitempty"`
+		Credit            *CreditInstruction `json:"credit,omitempty"`
+		Amount            float64            `json:"amount"`
+		Maker             string             `json:"maker"`
+		CreatedAt         time.Time          `json:"created_at"`
+		ExpiresAt         time.Time          `json:"expires_at"`
+		RequiredApprovals int                `json:"required_approvals"`
+		Approvals         []Approval         `json:"approvals"`
+		Status            ApprovalStatus     `json:"status"`
+		ResponseCode      string             `json:"response_code,omitempty"`
+		Message           string             `json:"message,omitempty"`
+		Error             string             `json:"error,omitempty"`
+		CompletedAt       time.Time          `json:"completed_at,omitempty"`
+	}
+
+	//AuditEntry records one action on a request. Entries are only ever appended.
+	AuditEntry struct {
+		RequestID string      `json:"request_id"`
+		Action    AuditAction `json:"action"`
+		Actor     string      `json:"actor"`
+		At        time.Time   `json:"at"`
+		Detail    string      `json:"detail,omitempty"`
+	}
+
+	//ApprovalStore keeps requests and their audit trail
+	ApprovalStore interface {
+		SaveRequest(request ApprovalRequest) error
+		//LoadRequest returns found false when there is no request with id
+		LoadRequest(id string) (request ApprovalRequest, found bool, err error)
+		ListRequests() ([]ApprovalRequest, error)
+		AppendAudit(entry AuditEntry) error
+		AuditTrail(requestID string) ([]AuditEntry, error)
+	}
+
+	//ApprovalWorkflow is a maker-checker workflow: makers submit payouts and credits as pending requests,
+	//checkers other than the maker approve or reject them, and approved requests are executed through
+	//Payouts or Wallets. Requests not executed by their deadline expire.
+	ApprovalWorkflow struct {
+		Policy  ApprovalPolicy
+		Store   ApprovalStore
+		Payouts PayoutService  //Required for payout requests, e.g. WalletsAfrica.Payouts or LimitedPayouts
+		Wallets WalletCreditor //Required for credit requests, e.g. WalletsAfrica.Wallets or LimitedWallets
+		Banks   *BankDirectory //Checks payout bank codes, defaults to the bank list from Payouts
+		//ValidateAccountNumbers checks payout account numbers with ValidateNUBAN, see Config.ValidateAccountNumbers.
+		//Without it only the 10 digit format is checked.
+		ValidateAccountNumbers bool
+
+		mu          sync.Mutex
+		now         func() time.Time
+		payoutBanks *BankDirectory //Built from Payouts when Banks is not set
+	}
+
+	//MemoryApprovalStore keeps requests and the audit trail in memory
+	MemoryApprovalStore struct {
+		mu       sync.Mutex
+		requests map[string]ApprovalRequest
+		audit    []AuditEntry
+	}
+
+	//FileApprovalStore keeps one JSON file per request in Dir and appends the audit trail to audit.jsonl
+	FileApprovalStore struct {
+		Dir string
+		mu  sync.Mutex
+	}
+)
+
+//Required returns the number of approvals needed for amount
+func (p ApprovalPolicy) Required(amount float64) int {
+	required := 0
+	for _, threshold := range p.Thresholds {
+		if amount > threshold.Above && threshold.Approvals > required {
+			required = threshold.Approvals
+		}
+	}
+	return required
+}
+
+//Final reports whether the request can no longer change
+func (r ApprovalRequest) Final() bool {
+	switch r.Status {
+	case ApprovalRejected, ApprovalExpired, ApprovalExecuted, ApprovalFailed:
+		return true
+	}
+	return false
+}
+
+//Approvers lists who approved the request, in order
+func (r ApprovalRequest) Approvers() []string {
+	approvers := []string{}
+	for _, approval := range r.Approvals {
+		approvers = append(approvers, approval.Approver)
+	}
+	return approvers
+}
+
+//SubmitPayout creates a request for instruction made by maker. Its reference becomes the request ID.
+func (w *ApprovalWorkflow) SubmitPayout(maker string, instruction PayoutInstruction) (ApprovalRequest, error) {
+	if instruction.BankCode == "" || instruction.AccountNumber == "" {
+		return ApprovalRequest{}, errors.New("approval - payout bank code and account number are required")
+	}
+
+	if err := w.validateAccount(instruction.BankCode, instruction.AccountNumber); err != nil {
+		return ApprovalRequest{}, err
+	}
+
+	return w.submit(maker, ApprovalRequest{
+		ID:     instruction.Reference,
+		Kind:   ApprovalKindPayout,
+		Payout: &instruction,
+		Amount: instruction.Amount,
+	}, fmt.Sprintf("payout of %v to %v/%v (%v)", FormatAmount(instruction.Amount, CurrencyNigeria), instruction.BankCode, instruction.AccountNumber, instruction.AccountName))
+}
+
+//SubmitCredit creates a request for instruction made by maker. Its reference becomes the request ID.
+func (w *ApprovalWorkflow) SubmitCredit(maker string, instruction CreditInstruction) (ApprovalRequest, error) {
+	if !isPhoneNumber(instruction.PhoneNumber) {
+		return ApprovalRequest{}, errors.New(fmt.Sprintf("approval - %v is not a valid phone number", instruction.PhoneNumber))
+	}
+
+	return w.submit(maker, ApprovalRequest{
+		ID:     instruction.Reference,
+		Kind:   ApprovalKindCredit,
+		Credit: &instruction,
+		Amount: instruction.Amount,
+	}, fmt.Sprintf("credit of %v to %v", FormatAmount(instruction.Amount, CurrencyNigeria), instruction.PhoneNumber))
+}
+
+//Approve records approver's approval. The maker cannot approve their own request and every approver counts once.
+func (w *ApprovalWorkflow) Approve(id, approver, comment string) (ApprovalRequest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	request, err := w.load(id)
+	if err != nil {
+		return ApprovalRequest{}, err
+	}
+
+	if request.Status != ApprovalPending {
+		return request, errors.New(fmt.Sprintf("approval - request %v is %v", id, request.Status))
+	}
+
+	switch approver = strings.TrimSpace(approver); {
+	case approver == "":
+		return request, errors.New("approval - approver is required")
+	case strings.EqualFold(approver, request.Maker):
+		return request, errors.New(fmt.Sprintf("approval - %v made request %v and cannot approve it", approver, id))
+	}
+
+	for _, approval := range request.Approvals {
+		if strings.EqualFold(approval.Approver, approver) {
+			return request, errors.New(fmt.Sprintf("approval - %v already approved request %v", approver, id))
+		}
+	}
+
+	now := w.clock()
+	request.Approvals = append(request.Approvals, Approval{Approver: approver, At: now, Comment: comment})
+	if len(request.Approvals) >= request.RequiredApprovals {
+		request.Status = ApprovalApproved
+	}
+
+	detail := fmt.Sprintf("%v of %v approvals", len(request.Approvals), request.RequiredApprovals)
+	if comment != "" {
+		detail += " - " + comment
+	}
+	return request, w.record(request, AuditApproved, approver, now, detail)
+}
+
+//Reject rejects a pending or approved request for good. The maker may reject their own request to withdraw it.
+func (w *ApprovalWorkflow) Reject(id, approver, reason string) (ApprovalRequest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	request, err := w.load(id)
+	if err != nil {
+		return ApprovalRequest{}, err
+	}
+
+	if request.Status != ApprovalPending && request.Status != ApprovalApproved {
+		return request, errors.New(fmt.Sprintf("approval - request %v is %v", id, request.Status))
+	}
+
+	if strings.TrimSpace(approver) == "" {
+		return request, errors.New("approval - approver is required")
+	}
+
+	now := w.clock()
+	request.Status = ApprovalRejected
+	request.CompletedAt = now
+	return request, w.record(request, AuditRejected, approver, now, reason)
+}
+
+//Execute sends an approved request through Payouts or Wallets. A request that was not sent or that the API
+//rejected fails; submit a new request instead. A failure such as a timeout does not prove the money did not
+//move, so the request is marked ApprovalUnknown until someone checks with the bank and calls Resolve.
+func (w *ApprovalWorkflow) Execute(id, actor string) (ApprovalRequest, error) {
+	if strings.TrimSpace(actor) == "" {
+		return ApprovalRequest{}, errors.New("approval - actor is required")
+	}
+
+	w.mu.Lock()
+	request, err := w.load(id)
+	if err != nil {
+		w.mu.Unlock()
+		return ApprovalRequest{}, err
+	}
+
+	if request.Status != ApprovalApproved {
+		w.mu.Unlock()
+		return request, errors.New(fmt.Sprintf("approval - request %v is %v", id, request.Status))
+	}
+
+	//Marking the request before the call stops a second Execute from sending it again
+	request.Status = ApprovalExecuting
+	err = w.record(request, AuditExecuting, actor, w.clock(), "")
+	w.mu.Unlock()
+	if err != nil {
+		return request, err
+	}
+
+	var sendErr error
+	switch request.Kind {
+	case ApprovalKindPayout:
+		if w.Payouts == nil {
+			sendErr = notSent(errors.New("approval - no payout service configured"))
+			break
+		}
+		p := request.Payout
+		var result BankTransferResult
+		result, sendErr = w.Payouts.BankTransfer(p.Amount, p.BankCode, p.AccountNumber, p.AccountName, p.Narration, p.Reference)
+		request.ResponseCode, request.Message = result.ResponseCode, result.Message
+	case ApprovalKindCredit:
+		if w.Wallets == nil {
+			sendErr = notSent(errors.New("approval - no wallet service configured"))
+			break
+		}
+		c := request.Credit
+		var result CreditWalletResult
+		result, sendErr = w.Wallets.Credit(c.Amount, c.Reference, c.PhoneNumber)
+		if sendErr == nil {
+			request.Message = fmt.Sprintf("recipient balance %v", FormatAmount(result.RecipientWalletBalance, CurrencyNigeria))
+		}
+	default:
+		sendErr = notSent(errors.New(fmt.Sprintf("approval - unknown request kind %v", request.Kind)))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock()
+	request.CompletedAt = now
+	if sendErr != nil {
+		request.Status, request.Error = ApprovalFailed, sendErr.Error()
+		action := AuditFailed
+		if !failedWithoutEffect(sendErr) {
+			request.Status, action = ApprovalUnknown, AuditUnknown
+		}
+
+		if err := w.record(request, action, actor, now, request.Error); err != nil {
+			return request, err
+		}
+		return request, sendErr
+	}
+
+	request.Status = ApprovalExecuted
+	return request, w.record(request, AuditExecuted, actor, now, request.Message)
+}
+
+//Resolve records the outcome of a request in ApprovalUnknown, or one left executing by a crash, once someone
+//has checked with the bank whether the money moved. Do not resolve a request that is still being executed.
+func (w *ApprovalWorkflow) Resolve(id, actor string, executed bool, detail string) (ApprovalRequest, error) {
+	if strings.TrimSpace(actor) == "" {
+		return ApprovalRequest{}, errors.New("approval - actor is required")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	request, err := w.load(id)
+	if err != nil {
+		return ApprovalRequest{}, err
+	}
+
+	if request.Status != ApprovalUnknown && request.Status != ApprovalExecuting {
+		return request, errors.New(fmt.Sprintf("approval - request %v is %v", id, request.Status))
+	}
+
+	now := w.clock()
+	request.Status, request.CompletedAt = ApprovalFailed, now
+	if executed {
+		request.Status = ApprovalExecuted
+	}
+	if detail != "" {
+		detail = fmt.Sprintf("%v - %v", request.Status, detail)
+	} else {
+		detail = string(request.Status)
+	}
+	return request, w.record(request, AuditResolved, actor, now, detail)
+}
+
+//Expire marks every pending or approved request past its deadline as expired and returns them.
+//Requests are also expired when they are next touched, so calling Expire only keeps listings current.
+func (w *ApprovalWorkflow) Expire() ([]ApprovalRequest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	requests, err := w.Store.ListRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []ApprovalRequest{}
+	for _, request := range requests {
+		if ok, err := w.expire(&request); err != nil {
+			return expired, err
+		} else if ok {
+			expired = append(expired, request)
+		}
+	}
+	return expired, nil
+}
+
+//Request returns the request with id
+func (w *ApprovalWorkflow) Request(id string) (ApprovalRequest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.load(id)
+}
+
+//Pending lists requests waiting for approvals, oldest first, e.g. for a checker's inbox
+func (w *ApprovalWorkflow) Pending() ([]ApprovalRequest, error) {
+	if _, err := w.Expire(); err != nil {
+		return nil, err
+	}
+
+	requests, err := w.Store.ListRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []ApprovalRequest{}
+	for _, request := range requests {
+		if request.Status == ApprovalPending {
+			pending = append(pending, request)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending, nil
+}
+
+//AuditTrail returns every action taken on the request with id, oldest first
+func (w *ApprovalWorkflow) AuditTrail(id string) ([]AuditEntry, error) {
+	return w.Store.AuditTrail(id)
+}
+
+//validateAccount checks a payout bank code against Banks or the bank list from Payouts and the account number
+//as set by ValidateAccountNumbers. Without a bank list only the account number can be checked.
+func (w *ApprovalWorkflow) validateAccount(bankCode, accountNumber string) error {
+	banks := w.bankDirectory()
+	if banks == nil {
+		return checkAccountNumber(Bank{}, accountNumber, false)
+	}
+
+	bank, ok, err := banks.ByCode(bankCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown bank code %v", bankCode))
+	}
+	return checkAccountNumber(bank, accountNumber, w.ValidateAccountNumbers)
+}
+
+//bankDirectory returns Banks, or a directory over Payouts built on first use so its bank list is cached
+func (w *ApprovalWorkflow) bankDirectory() *BankDirectory {
+	if w.Banks != nil {
+		return w.Banks
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.payoutBanks == nil && w.Payouts != nil {
+		w.payoutBanks = NewBankDirectory(w.Payouts, 0, "")
+	}
+	return w.payoutBanks
+}
+
+func (w *ApprovalWorkflow) submit(maker string, request ApprovalRequest, description string) (ApprovalRequest, error) {
+	if w.Store == nil {
+		return ApprovalRequest{}, errors.New("approval - store is required")
+	}
+
+	switch maker = strings.TrimSpace(maker); {
+	case maker == "":
+		return ApprovalRequest{}, errors.New("approval - maker is required")
+	case request.ID == "":
+		return ApprovalRequest{}, errors.New("approval - reference is required")
+	case request.Amount <= 0:
+		return ApprovalRequest{}, errors.New(fmt.Sprintf("approval - amount %v must be greater than zero", request.Amount))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, found, err := w.Store.LoadRequest(request.ID); err != nil {
+		return ApprovalRequest{}, err
+	} else if found {
+		return ApprovalRequest{}, errors.New(fmt.Sprintf("approval - reference %v is already used", request.ID))
+	}
+
+	ttl := w.Policy.TTL
+	if ttl <= 0 {
+		ttl = DefaultApprovalTTL
+	}
+
+	now := w.clock()
+	request.Maker = maker
+	request.CreatedAt = now
+	request.ExpiresAt = now.Add(ttl)
+	request.RequiredApprovals = w.Policy.Required(request.Amount)
+	request.Approvals = []Approval{}
+	request.Status = ApprovalPending
+	if request.RequiredApprovals == 0 {
+		request.Status = ApprovalApproved
+	}
+
+	return request, w.record(request, AuditCreated, maker, now, fmt.Sprintf("%v, %v approvals required", description, request.RequiredApprovals))
+}
+
+//load returns the request with id, expiring it first if its deadline passed. The caller holds w.mu.
+func (w *ApprovalWorkflow) load(id string) (ApprovalRequest, error) {
+	request, found, err := w.Store.LoadRequest(id)
+	if err != nil {
+		return ApprovalRequest{}, err
+	}
+
+	if !found {
+		return ApprovalRequest{}, errors.New(fmt.Sprintf("approval - request %v not found", id))
+	}
+
+	if _, err := w.expire(&request); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+//expire marks request expired if it is waiting past its deadline. The caller holds w.mu.
+func (w *ApprovalWorkflow) expire(request *ApprovalRequest) (bool, error) {
+	now := w.clock()
+	if (request.Status != ApprovalPending && request.Status != ApprovalApproved) || now.Before(request.ExpiresAt) {
+		return false, nil
+	}
+
+	request.Status = ApprovalExpired
+	request.CompletedAt = now
+	return true, w.record(*request, AuditExpired, AuditActorSystem, now, fmt.Sprintf("%v of %v approvals", len(request.Approvals), request.RequiredApprovals))
+}
+
+//record saves request and appends the audit entry for the change. The caller holds w.mu.
+func (w *ApprovalWorkflow) record(request ApprovalRequest, action AuditAction, actor string, at time.Time, detail string) error {
+	if err := w.Store.SaveRequest(request); err != nil {
+		return err
+	}
+	return w.Store.AppendAudit(AuditEntry{RequestID: request.ID, Action: action, Actor: actor, At: at, Detail: detail})
+}
+
+func (w *ApprovalWorkflow) clock() time.Time {
+	if w.now == nil {
+		return time.Now()
+	}
+	return w.now()
+}
+
+//NewMemoryApprovalStore creates an empty in-memory store
+func NewMemoryApprovalStore() *MemoryApprovalStore {
+	return &MemoryApprovalStore{requests: map[string]ApprovalRequest{}}
+}
+
+func (m *MemoryApprovalStore) SaveRequest(request ApprovalRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == nil {
+		m.requests = map[string]ApprovalRequest{}
+	}
+	request.Approvals = append([]Approval(nil), request.Approvals...)
+	m.requests[request.ID] = request
+	return nil
+}
+
+func (m *MemoryApprovalStore) LoadRequest(id string) (ApprovalRequest, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request, found := m.requests[id]
+	request.Approvals = append([]Approval(nil), request.Approvals...)
+	return request, found, nil
+}
+
+func (m *MemoryApprovalStore) ListRequests() ([]ApprovalRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := []ApprovalRequest{}
+	for _, request := range m.requests {
+		request.Approvals = append([]Approval(nil), request.Approvals...)
+		requests = append(requests, request)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	return requests, nil
+}
+
+func (m *MemoryApprovalStore) AppendAudit(entry AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+func (m *MemoryApprovalStore) AuditTrail(requestID string) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []AuditEntry{}
+	for _, entry := range m.audit {
+		if entry.RequestID == requestID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (f *FileApprovalStore) SaveRequest(request ApprovalRequest) error {
+	raw, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return writeFileAtomic(f.requestPath(request.ID), raw)
+}
+
+func (f *FileApprovalStore) LoadRequest(id string) (ApprovalRequest, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, err := ioutil.ReadFile(f.requestPath(id))
+	if os.IsNotExist(err) {
+		return ApprovalRequest{}, false, nil
+	}
+	if err != nil {
+		return ApprovalRequest{}, false, err
+	}
+
+	request := ApprovalRequest{}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return ApprovalRequest{}, false, errors.New(fmt.Sprintf("approval request %v - %v", id, err))
+	}
+
+	if request.ID != id {
+		return ApprovalRequest{}, false, errors.New(fmt.Sprintf("approval request %v - file holds request %v", id, request.ID))
+	}
+	return request, true, nil
+}
+
+func (f *FileApprovalStore) ListRequests() ([]ApprovalRequest, error) {
+	f.mu.Lock()
+	paths, err := filepath.Glob(filepath.Join(f.Dir, "request-*.json"))
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []ApprovalRequest{}
+	for _, path := range paths {
+		encoded := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "request-"), ".json")
+		id, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("approval store - %v is not a request file", path))
+		}
+
+		request, found, err := f.LoadRequest(string(id))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			requests = append(requests, request)
+		}
+	}
+	return requests, nil
+}
+
+func (f *FileApprovalStore) AppendAudit(entry AuditEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(f.Dir, "audit.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(raw, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *FileApprovalStore) AuditTrail(requestID string) ([]AuditEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries := []AuditEntry{}
+	file, err := os.Open(filepath.Join(f.Dir, "audit.jsonl"))
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		if entry.RequestID == requestID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+//requestPath encodes id in the file name, so IDs with path separators or dots cannot collide or leave Dir
+func (f *FileApprovalStore) requestPath(id string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("request-%v.json", base64.RawURLEncoding.EncodeToString([]byte(id))))
+}
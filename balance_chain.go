@@ -0,0 +1,226 @@
+package gowalletsafrica
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	FindingAmountMismatch  FindingKind = "amount_mismatch"
+	FindingGap             FindingKind = "gap"
+	FindingDuplicate       FindingKind = "duplicate"
+	FindingBalanceMismatch FindingKind = "balance_mismatch"
+	FindingInvalidEntry    FindingKind = "invalid_entry"
+)
+
+type (
+	FindingKind string
+
+	//Finding describes one problem found in a transaction history.
+	//Index points into ChainReport.Transactions and is -1 for findings about the whole history.
+	Finding struct {
+		Kind        FindingKind
+		Index       int
+		Transaction Transaction
+		Expected    float64
+		Actual      float64
+		Message     string
+	}
+
+	//ChainReport is the result of verifying a transaction history.
+	//Transactions holds the history in the order it was verified, oldest first.
+	ChainReport struct {
+		Currency       Currency
+		Transactions   Transactions
+		OpeningBalance float64
+		ClosingBalance float64
+		Findings       []Finding
+	}
+)
+
+//VerifyBalanceChain walks transactions from oldest to newest and checks that every entry's
+//NewBalance equals PreviousBalance plus (Credit) or minus (Debit) Amount, that each entry starts
+//from the balance the previous one ended on, and that no entry appears twice.
+//All transactions are expected to be in the same currency.
+func VerifyBalanceChain(transactions Transactions) ChainReport {
+	report := ChainReport{Findings: []Finding{}}
+	if len(transactions) == 0 {
+		return report
+	}
+
+	report.Transactions = chainOrder(SortTransactions(transactions))
+	report.Currency = Currency(strings.ToUpper(report.Transactions[0].Currency))
+	report.OpeningBalance = report.Transactions[0].PreviousBalance
+	report.ClosingBalance = report.Transactions[len(report.Transactions)-1].NewBalance
+
+	seen := map[string]int{}
+	var previous *Transaction
+	for i, t := range report.Transactions {
+		fingerprint := t.Fingerprint()
+		if first, ok := seen[fingerprint]; ok {
+			report.add(Finding{Kind: FindingDuplicate, Index: i, Transaction: t, Message: fmt.Sprintf("entry repeats entry %v", first)})
+			continue
+		}
+		seen[fingerprint] = i
+
+		if !strings.EqualFold(t.Currency, string(report.Currency)) {
+			report.add(Finding{Kind: FindingInvalidEntry, Index: i, Transaction: t, Message: fmt.Sprintf("entry currency %v differs from %v", t.Currency, report.Currency)})
+			continue
+		}
+
+		if _, err := t.Time(); err != nil {
+			report.add(Finding{Kind: FindingInvalidEntry, Index: i, Transaction: t, Message: err.Error()})
+		}
+
+		signed, err := t.SignedAmount()
+		if err != nil {
+			report.add(Finding{Kind: FindingInvalidEntry, Index: i, Transaction: t, Message: err.Error()})
+		} else if expected := t.PreviousBalance + signed; !amountsEqual(expected, t.NewBalance, report.Currency) {
+			report.add(Finding{
+				Kind:        FindingAmountMismatch,
+				Index:       i,
+				Transaction: t,
+				Expected:    expected,
+				Actual:      t.NewBalance,
+				Message:     fmt.Sprintf("new balance %v does not equal previous balance %v %v amount %v", FormatAmount(t.NewBalance, report.Currency), FormatAmount(t.PreviousBalance, report.Currency), t.Type, FormatAmount(t.Amount, report.Currency)),
+			})
+		}
+
+		if previous != nil && !amountsEqual(previous.NewBalance, t.PreviousBalance, report.Currency) {
+			report.add(Finding{
+				Kind:        FindingGap,
+				Index:       i,
+				Transaction: t,
+				Expected:    previous.NewBalance,
+				Actual:      t.PreviousBalance,
+				Message:     fmt.Sprintf("previous balance %v does not continue from %v, %v unaccounted for", FormatAmount(t.PreviousBalance, report.Currency), FormatAmount(previous.NewBalance, report.Currency), FormatAmount(t.PreviousBalance-previous.NewBalance, report.Currency)),
+			})
+		}
+
+		current := t
+		previous = &current
+	}
+	return report
+}
+
+//OK reports whether verification produced no findings
+func (r ChainReport) OK() bool {
+	return len(r.Findings) == 0
+}
+
+//Reconcile compares the closing balance of the history with a live wallet balance.
+//It is only meaningful when the history runs up to the present.
+func (r *ChainReport) Reconcile(balance CheckBalanceResult) error {
+	if len(r.Transactions) == 0 {
+		return errors.New("cannot reconcile an empty transaction history")
+	}
+
+	if !strings.EqualFold(balance.WalletCurrency, string(r.Currency)) {
+		return errors.New(fmt.Sprintf("cannot reconcile %v history against a %v balance", r.Currency, balance.WalletCurrency))
+	}
+
+	if !amountsEqual(r.ClosingBalance, balance.WalletBalance, r.Currency) {
+		r.add(Finding{
+			Kind:     FindingBalanceMismatch,
+			Index:    -1,
+			Expected: balance.WalletBalance,
+			Actual:   r.ClosingBalance,
+			Message:  fmt.Sprintf("closing balance %v does not match wallet balance %v", FormatAmount(r.ClosingBalance, r.Currency), FormatAmount(balance.WalletBalance, r.Currency)),
+		})
+	}
+	return nil
+}
+
+//FindingsOf returns the findings of the given kind
+func (r ChainReport) FindingsOf(kind FindingKind) []Finding {
+	findings := []Finding{}
+	for _, f := range r.Findings {
+		if f.Kind == kind {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+//VerifyBalanceChain verifies transactions and reconciles the result against the current wallet
+//balance in currency. The history must run up to the present for the reconciliation to hold.
+func (s *self) VerifyBalanceChain(currency Currency, transactions Transactions) (ChainReport, error) {
+	report := VerifyBalanceChain(transactions)
+	if len(report.Transactions) == 0 {
+		return report, nil
+	}
+
+	balance, err := s.CheckBalance(currency)
+	if err != nil {
+		return report, err
+	}
+
+	if err := report.Reconcile(balance); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (r *ChainReport) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+}
+
+//chainOrder reorders entries sharing the same timestamp so that, where possible, each one starts
+//from the balance the previous one ended on. The API's ordering of such entries is not reliable.
+func chainOrder(sorted Transactions) Transactions {
+	ordered := make(Transactions, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && sorted[end].DateTransacted == sorted[start].DateTransacted {
+			end++
+		}
+
+		group := make(Transactions, end-start)
+		copy(group, sorted[start:end])
+		for len(group) > 0 {
+			next := -1
+			if len(ordered) > 0 {
+				last := ordered[len(ordered)-1]
+				for i, t := range group {
+					if amountsEqual(last.NewBalance, t.PreviousBalance, Currency(t.Currency)) {
+						next = i
+						break
+					}
+				}
+			}
+
+			//Without a link to the previous entry, start from the one no other entry in the group leads to
+			if next < 0 {
+				next = 0
+				for i, t := range group {
+					if !leadsTo(group, t) {
+						next = i
+						break
+					}
+				}
+			}
+			ordered = append(ordered, group[next])
+			group = append(group[:next], group[next+1:]...)
+		}
+		start = end
+	}
+	return ordered
+}
+
+//leadsTo reports whether any entry in group ends on the balance t starts from
+func leadsTo(group Transactions, t Transaction) bool {
+	for _, other := range group {
+		if other != t && amountsEqual(other.NewBalance, t.PreviousBalance, Currency(t.Currency)) {
+			return true
+		}
+	}
+	return false
+}
+
+//amountsEqual compares two amounts in the currency's minor units to avoid float drift
+func amountsEqual(a, b float64, currency Currency) bool {
+	scale := math.Pow10(CurrencyMinorUnits(currency))
+	return math.Round(a*scale) == math.Round(b*scale)
+}
@@ -0,0 +1,187 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultBalanceConcurrency int = 4
+)
+
+//SupportedCurrencies is used by Balances when no currency is provided
+var SupportedCurrencies = []Currency{CurrencyNigeria, CurrencyUSA, CurrencyGhana, CurrencyKenya}
+
+type (
+	//BalanceChecker is satisfied by WalletsAfrica.Self
+	BalanceChecker interface {
+		CheckBalance(currency Currency) (CheckBalanceResult, error)
+	}
+
+	//CurrencyBalance is the balance of one currency, or the error fetching it
+	CurrencyBalance struct {
+		Currency Currency
+		Balance  float64
+		Err      error
+	}
+
+	PortfolioBalances map[Currency]CurrencyBalance
+
+	//FXRateProvider returns how many units of to one unit of from is worth
+	FXRateProvider interface {
+		Rate(from, to Currency) (float64, error)
+	}
+
+	//StaticFXRates is an FXRateProvider backed by fixed rates keyed "FROM/TO", e.g. "USD/NGN".
+	//Inverse pairs are derived when only one direction is present.
+	StaticFXRates map[string]float64
+
+	//PortfolioValue is a portfolio converted into a single reporting currency
+	PortfolioValue struct {
+		Currency  Currency
+		Total     float64
+		Converted map[Currency]float64 //Each balance in the reporting currency
+		Errors    map[Currency]error   //Balances that could not be fetched or converted
+	}
+)
+
+//Balances fetches the balance of every currency concurrently, at most DefaultBalanceConcurrency at a
+//time. All supported currencies are fetched when none is provided. Per-currency failures are
+//reported in the result; the error is only set when ctx ends before every balance was fetched.
+func (s *self) Balances(ctx context.Context, currencies ...Currency) (PortfolioBalances, error) {
+	return FetchBalances(ctx, s, DefaultBalanceConcurrency, currencies...)
+}
+
+//FetchBalances is Balances for any BalanceChecker with a custom concurrency limit
+func FetchBalances(ctx context.Context, checker BalanceChecker, concurrency int, currencies ...Currency) (PortfolioBalances, error) {
+	if len(currencies) == 0 {
+		currencies = SupportedCurrencies
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	balances := PortfolioBalances{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	slots := make(chan struct{}, concurrency)
+
+	for _, currency := range currencies {
+		currency = Currency(strings.ToUpper(string(currency)))
+		mu.Lock()
+		_, duplicate := balances[currency]
+		if !duplicate {
+			//Placeholder until the fetch runs, so currencies skipped on cancellation are still reported
+			balances[currency] = CurrencyBalance{Currency: currency, Err: context.Canceled}
+		}
+		mu.Unlock()
+
+		if duplicate {
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		wg.Add(1)
+		go func(currency Currency) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			//CheckBalance does not take a context, so only skip fetches that have not started yet
+			result := CurrencyBalance{Currency: currency}
+			if err := ctx.Err(); err != nil {
+				result.Err = err
+			} else if balance, err := checker.CheckBalance(currency); err != nil {
+				result.Err = err
+			} else {
+				result.Balance = balance.WalletBalance
+			}
+
+			mu.Lock()
+			balances[currency] = result
+			mu.Unlock()
+		}(currency)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for currency, balance := range balances {
+			if balance.Err == context.Canceled {
+				balance.Err = err
+				balances[currency] = balance
+			}
+		}
+		return balances, err
+	}
+	return balances, nil
+}
+
+//Currencies returns the currencies in the portfolio in alphabetical order
+func (p PortfolioBalances) Currencies() []Currency {
+	currencies := make([]Currency, 0, len(p))
+	for currency := range p {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+	return currencies
+}
+
+//Convert values every fetched balance in the reporting currency using provider.
+//Balances that failed to fetch or convert are listed in PortfolioValue.Errors and left out of Total.
+func (p PortfolioBalances) Convert(provider FXRateProvider, to Currency) (PortfolioValue, error) {
+	to = Currency(strings.ToUpper(string(to)))
+	value := PortfolioValue{Currency: to, Converted: map[Currency]float64{}, Errors: map[Currency]error{}}
+
+	for _, currency := range p.Currencies() {
+		balance := p[currency]
+		if balance.Err != nil {
+			value.Errors[currency] = balance.Err
+			continue
+		}
+
+		rate := 1.0
+		if currency != to {
+			if provider == nil {
+				return value, errors.New("portfolio conversion - fx rate provider is required")
+			}
+
+			var err error
+			rate, err = provider.Rate(currency, to)
+			if err != nil {
+				value.Errors[currency] = err
+				continue
+			}
+		}
+
+		converted := balance.Balance * rate
+		value.Converted[currency] = converted
+		value.Total += converted
+	}
+	return value, nil
+}
+
+func (r StaticFXRates) Rate(from, to Currency) (float64, error) {
+	from = Currency(strings.ToUpper(string(from)))
+	to = Currency(strings.ToUpper(string(to)))
+	if from == to {
+		return 1, nil
+	}
+
+	if rate, ok := r[fmt.Sprintf("%v/%v", from, to)]; ok && rate > 0 {
+		return rate, nil
+	}
+
+	if rate, ok := r[fmt.Sprintf("%v/%v", to, from)]; ok && rate > 0 {
+		return 1 / rate, nil
+	}
+	return 0, errors.New(fmt.Sprintf("no fx rate from %v to %v", from, to))
+}
@@ -0,0 +1,440 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultBankDirectoryTTL time.Duration = 24 * time.Hour
+
+	bankSearchMinScore float64 = 0.6
+)
+
+//BankAliases maps common short names, normalized with NormalizeBankName, to the normalized name of the
+//bank they refer to. Add entries for names your users type that the directory does not resolve.
+var BankAliases = map[string]string{
+	"gtb":        "guaranty trust",
+	"gtbank":     "guaranty trust",
+	"gtco":       "guaranty trust",
+	"fbn":        "first",
+	"firstbank":  "first",
+	"uba":        "united for africa",
+	"fcmb":       "first city monument",
+	"alat":       "alat by wema",
+	"wemabank":   "wema",
+	"stanbic":    "stanbic ibtc",
+	"ibtc":       "stanbic ibtc",
+	"eco":        "ecobank",
+	"fidelity":   "fidelity",
+	"skye":       "polaris",
+	"diamond":    "access diamond",
+	"standard":   "standard chartered",
+	"scb":        "standard chartered",
+	"citi":       "citibank",
+	"providus":   "providus",
+	"kuda":       "kuda microfinance",
+	"opay":       "opay digital services",
+	"palmpay":    "palmpay",
+	"moniepoint": "moniepoint microfinance",
+}
+
+//bankNameStopWords are dropped by NormalizeBankName since banks are listed with and without them
+var bankNameStopWords = map[string]bool{"bank": true, "plc": true, "ltd": true, "limited": true, "nigeria": true, "ng": true, "the": true, "of": true, "mfb": true}
+
+type (
+	//BankLister is satisfied by WalletsAfrica.Payouts
+	BankLister interface {
+		GetBanks() (Banks, error)
+	}
+
+	BankMatch struct {
+		Bank  Bank
+		Score float64 //1 for an exact code, name or alias match, lower for partial and fuzzy matches
+	}
+
+	//BankDirectory caches the bank list from Source for TTL. Once the list is stale it keeps serving it
+	//while a refresh runs in the background. With SnapshotPath set the list is saved to disk after
+	//every refresh and loaded on a cold start, so a restart does not have to wait for Source.
+	BankDirectory struct {
+		Source       BankLister
+		TTL          time.Duration
+		SnapshotPath string
+		OnError      func(err error) //Receives background refresh and snapshot errors
+
+		loadMu     sync.Mutex //Held during a cold start so concurrent callers share one load
+		mu         sync.RWMutex
+		banks      Banks
+		fetchedAt  time.Time
+		byCode     map[string]Bank
+		bySortCode map[string]Bank
+		byName     map[string]Bank
+		refreshing bool
+	}
+
+	bankSnapshot struct {
+		FetchedAt time.Time `json:"fetched_at"`
+		Banks     Banks     `json:"banks"`
+	}
+)
+
+//NewBankDirectory creates a directory over source. snapshotPath may be empty to disable snapshots.
+func NewBankDirectory(source BankLister, ttl time.Duration, snapshotPath string) *BankDirectory {
+	return &BankDirectory{Source: source, TTL: ttl, SnapshotPath: snapshotPath}
+}
+
+//Banks returns the cached bank list, loading the snapshot or fetching from Source on first use
+func (d *BankDirectory) Banks() (Banks, error) {
+	if err := d.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append(Banks(nil), d.banks...), nil
+}
+
+//GetBanks makes a BankDirectory a drop in BankLister, e.g. for PayoutBatch
+func (d *BankDirectory) GetBanks() (Banks, error) {
+	return d.Banks()
+}
+
+//Refresh fetches the bank list from Source now, replaces the cache and saves the snapshot
+func (d *BankDirectory) Refresh() error {
+	if d.Source == nil {
+		return errors.New("bank directory - source is required")
+	}
+
+	banks, err := d.Source.GetBanks()
+	if err != nil {
+		return err
+	}
+
+	if len(banks) == 0 {
+		return errors.New("bank directory - source returned no banks")
+	}
+
+	fetchedAt := time.Now()
+	d.set(banks, fetchedAt)
+
+	if d.SnapshotPath != "" {
+		return d.saveSnapshot(bankSnapshot{FetchedAt: fetchedAt, Banks: banks})
+	}
+	return nil
+}
+
+//Run refreshes the directory every TTL until ctx is cancelled, returning ctx's error.
+//Refresh errors are reported to OnError; the previous list keeps being served.
+func (d *BankDirectory) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.ttl())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := d.Refresh(); err != nil {
+				d.reportError(err)
+			}
+		}
+	}
+}
+
+//ByCode finds a bank by its BankCode. The error is set when the bank list could not be loaded.
+func (d *BankDirectory) ByCode(code string) (Bank, bool, error) {
+	return d.lookup(func() map[string]Bank { return d.byCode }, strings.ToUpper(strings.TrimSpace(code)))
+}
+
+//BySortCode finds a bank by its BankSortCode. The error is set when the bank list could not be loaded.
+func (d *BankDirectory) BySortCode(sortCode string) (Bank, bool, error) {
+	return d.lookup(func() map[string]Bank { return d.bySortCode }, strings.TrimSpace(sortCode))
+}
+
+//ByName finds a bank by its name or an alias in BankAliases, ignoring case, punctuation and words
+//such as "Plc" and "Bank". The error is set when the bank list could not be loaded.
+func (d *BankDirectory) ByName(name string) (Bank, bool, error) {
+	normalized := NormalizeBankName(name)
+	if alias, ok := BankAliases[strings.Replace(normalized, " ", "", -1)]; ok {
+		if bank, ok, err := d.lookup(func() map[string]Bank { return d.byName }, alias); ok || err != nil {
+			return bank, ok, err
+		}
+	}
+	return d.lookup(func() map[string]Bank { return d.byName }, normalized)
+}
+
+//Search returns up to limit banks matching query, best match first. The query may be a bank code,
+//sort code, alias, acronym or a misspelt or partial name. A limit of 0 returns every match.
+//A query without letters or digits matches nothing.
+func (d *BankDirectory) Search(query string, limit int) ([]BankMatch, error) {
+	query = strings.TrimSpace(query)
+	normalized := NormalizeBankName(query)
+	if normalized == "" {
+		return []BankMatch{}, nil
+	}
+
+	banks, err := d.Banks()
+	if err != nil {
+		return nil, err
+	}
+
+	alias := BankAliases[strings.Replace(normalized, " ", "", -1)]
+
+	matches := []BankMatch{}
+	for _, bank := range banks {
+		if score := bankMatchScore(bank, query, normalized, alias); score >= bankSearchMinScore {
+			matches = append(matches, BankMatch{Bank: bank, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Bank.BankName < matches[j].Bank.BankName
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+//NormalizeBankName lower cases name, replaces punctuation with spaces and drops words such as "Plc",
+//"Bank" and "Nigeria", e.g. "Access Bank Nigeria Plc." becomes "access"
+func NormalizeBankName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kept := []string{}
+	for _, word := range words {
+		if !bankNameStopWords[word] {
+			kept = append(kept, word)
+		}
+	}
+
+	if len(kept) == 0 {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(kept, " ")
+}
+
+func (d *BankDirectory) ensureLoaded() error {
+	loaded, stale := d.state()
+	if !loaded {
+		//Callers arriving during a cold start wait for it instead of each calling Source
+		d.loadMu.Lock()
+		defer d.loadMu.Unlock()
+		loaded, stale = d.state()
+	}
+
+	if !loaded && d.SnapshotPath != "" {
+		snapshot, err := d.loadSnapshot()
+		if err != nil && !os.IsNotExist(err) {
+			d.reportError(err)
+		}
+
+		if err == nil && len(snapshot.Banks) > 0 {
+			d.set(snapshot.Banks, snapshot.FetchedAt)
+			loaded = true
+			stale = time.Since(snapshot.FetchedAt) > d.ttl()
+		}
+	}
+
+	if !loaded {
+		return d.Refresh()
+	}
+
+	if stale {
+		d.refreshInBackground()
+	}
+	return nil
+}
+
+//state reports whether a bank list is loaded and whether it is older than the TTL
+func (d *BankDirectory) state() (loaded bool, stale bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	loaded = d.banks != nil
+	return loaded, loaded && time.Since(d.fetchedAt) > d.ttl()
+}
+
+func (d *BankDirectory) refreshInBackground() {
+	d.mu.Lock()
+	if d.refreshing {
+		d.mu.Unlock()
+		return
+	}
+	d.refreshing = true
+	d.mu.Unlock()
+
+	go func() {
+		err := d.Refresh()
+
+		d.mu.Lock()
+		d.refreshing = false
+		d.mu.Unlock()
+
+		if err != nil {
+			d.reportError(err)
+		}
+	}()
+}
+
+func (d *BankDirectory) set(banks Banks, fetchedAt time.Time) {
+	byCode := map[string]Bank{}
+	bySortCode := map[string]Bank{}
+	byName := map[string]Bank{}
+	for _, bank := range banks {
+		byCode[strings.ToUpper(bank.BankCode)] = bank
+		if bank.BankSortCode != "" {
+			bySortCode[bank.BankSortCode] = bank
+		}
+		byName[NormalizeBankName(bank.BankName)] = bank
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.banks = append(Banks{}, banks...)
+	d.fetchedAt = fetchedAt
+	d.byCode, d.bySortCode, d.byName = byCode, bySortCode, byName
+}
+
+func (d *BankDirectory) lookup(index func() map[string]Bank, key string) (Bank, bool, error) {
+	if key == "" {
+		return Bank{}, false, nil
+	}
+
+	if err := d.ensureLoaded(); err != nil {
+		return Bank{}, false, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	bank, ok := index()[key]
+	return bank, ok, nil
+}
+
+func (d *BankDirectory) loadSnapshot() (bankSnapshot, error) {
+	snapshot := bankSnapshot{}
+	raw, err := ioutil.ReadFile(d.SnapshotPath)
+	if err != nil {
+		return snapshot, err
+	}
+	return snapshot, json.Unmarshal(raw, &snapshot)
+}
+
+func (d *BankDirectory) saveSnapshot(snapshot bankSnapshot) error {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(d.SnapshotPath, raw)
+}
+
+func (d *BankDirectory) ttl() time.Duration {
+	if d.TTL <= 0 {
+		return DefaultBankDirectoryTTL
+	}
+	return d.TTL
+}
+
+func (d *BankDirectory) reportError(err error) {
+	if d.OnError != nil {
+		d.OnError(err)
+	}
+}
+
+//bankMatchScore rates how well bank matches a search query in the range [0, 1]
+func bankMatchScore(bank Bank, query, normalized, alias string) float64 {
+	name := NormalizeBankName(bank.BankName)
+
+	switch {
+	case strings.EqualFold(bank.BankCode, query) || bank.BankSortCode == query:
+		return 1
+	case name == normalized || (alias != "" && name == alias):
+		return 1
+	case alias != "" && strings.HasPrefix(name, alias):
+		return 0.95
+	case strings.Replace(normalized, " ", "", -1) == bankAcronym(bank.BankName):
+		return 0.9
+	case strings.HasPrefix(name, normalized):
+		return 0.85
+	case strings.Contains(name, normalized):
+		return 0.8
+	}
+
+	//Fuzzy match against the whole name and each word, so "guarantee" finds "Guaranty Trust Bank"
+	best := similarity(name, normalized)
+	for _, word := range strings.Fields(name) {
+		for _, queryWord := range strings.Fields(normalized) {
+			if s := similarity(word, queryWord); s > best {
+				best = s
+			}
+		}
+	}
+	return best * 0.8
+}
+
+//bankAcronym returns the initials of the significant words of name, e.g. "gtb" for "Guaranty Trust Bank"
+func bankAcronym(name string) string {
+	acronym := ""
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if word == "plc" || word == "ltd" || word == "limited" || word == "nigeria" || word == "of" || word == "for" {
+			continue
+		}
+		acronym += word[:1]
+	}
+	return acronym
+}
+
+//similarity is 1 minus the Levenshtein distance between a and b relative to the longer of the two
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(previous[len(rb)])/float64(longest)
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,150 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BatchItemSucceeded BatchItemStatus = "succeeded"
+	BatchItemFailed    BatchItemStatus = "failed"
+	BatchItemInvalid   BatchItemStatus = "invalid"
+	BatchItemValidated BatchItemStatus = "validated" //Passed validation in a dry run
+	BatchItemSkipped   BatchItemStatus = "skipped"
+
+	DefaultBatchConcurrency int = 4
+)
+
+type (
+	BatchItemStatus string
+
+	//rateLimiter spaces calls out evenly to at most perSecond calls per second
+	rateLimiter struct {
+		mu       sync.Mutex
+		interval time.Duration
+		next     time.Time
+	}
+)
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+//wait blocks until the next call is allowed or ctx ends. A nil limiter never waits.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if r == nil {
+		return ctx.Err()
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	slot := r.next
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+//runConcurrently calls fn for every index in [0, n) with at most concurrency calls in flight.
+//No new calls are started once ctx ends; it returns ctx's error in that case.
+func runConcurrently(ctx context.Context, n, concurrency int, limiter *rateLimiter, fn func(i int)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	wg := sync.WaitGroup{}
+	slots := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		if err := limiter.wait(ctx); err != nil {
+			<-slots
+			wg.Wait()
+			return err
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+//readCSVRows reads a CSV file with a header row into one map per row, keyed by the lower cased
+//header name. Every name in required must be present in the header.
+func readCSVRows(r io.Reader, required ...string) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+
+	for _, name := range required {
+		found := false
+		for _, h := range header {
+			if h == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New(fmt.Sprintf("csv file is missing the %v column", name))
+		}
+	}
+
+	rows := []map[string]string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,265 @@
+package gowalletsafrica
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBVNCacheTTL        time.Duration = 30 * time.Minute
+	DefaultBVNCacheMaxEntries int           = 1000
+)
+
+type (
+	//BVNResolver is satisfied by WalletsAfrica.Identity
+	BVNResolver interface {
+		ResolveBVN(bvn string) (ResolveBVN, error)
+	}
+
+	BVNCacheOptions struct {
+		TTL        time.Duration //Defaults to DefaultBVNCacheTTL
+		MaxEntries int           //Least recently used entries are evicted beyond this, defaults to DefaultBVNCacheMaxEntries
+		Encryptor  *PIIEncryptor //When set, cached results are kept encrypted in memory
+	}
+
+	//BVNCacheStats counts lookups since the cache was created, e.g. to report saved lookup costs
+	BVNCacheStats struct {
+		Hits        int64
+		Misses      int64 //Lookups passed to the underlying resolver
+		Shared      int64 //Lookups that waited for an identical lookup in flight instead of calling the resolver
+		Evictions   int64
+		Expirations int64
+		Entries     int
+	}
+
+	//CachedBVNResolver wraps a BVNResolver and caches successful results per BVN for TTL.
+	//Failed lookups are not cached. Concurrent lookups of the same BVN share one call to the resolver.
+	CachedBVNResolver struct {
+		resolver BVNResolver
+		options  BVNCacheOptions
+		now      func() time.Time
+		keyHMAC  []byte //Random per cache, so cache keys cannot be matched against hashes of every possible BVN
+
+		mu       sync.Mutex
+		entries  map[string]*list.Element
+		order    *list.List //Front is the most recently used
+		inFlight map[string]*bvnLookup
+		stats    BVNCacheStats
+	}
+
+	bvnCacheEntry struct {
+		key       string
+		result    ResolveBVN
+		expiresAt time.Time
+	}
+
+	bvnLookup struct {
+		done        chan struct{}
+		result      ResolveBVN
+		err         error
+		invalidated bool //Set by Invalidate or Purge while the lookup runs, so its result is not cached
+	}
+)
+
+//NewCachedBVNResolver wraps resolver, e.g. WalletsAfrica.Identity, in a cache. It fails only when no random
+//key can be generated for the cache keys.
+func NewCachedBVNResolver(resolver BVNResolver, options BVNCacheOptions) (*CachedBVNResolver, error) {
+	if options.TTL <= 0 {
+		options.TTL = DefaultBVNCacheTTL
+	}
+
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = DefaultBVNCacheMaxEntries
+	}
+
+	keyHMAC := make([]byte, 32)
+	if _, err := rand.Read(keyHMAC); err != nil {
+		return nil, errors.New(fmt.Sprintf("bvn cache - could not generate a key - %v", err))
+	}
+
+	return &CachedBVNResolver{
+		resolver: resolver,
+		options:  options,
+		now:      time.Now,
+		keyHMAC:  keyHMAC,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		inFlight: map[string]*bvnLookup{},
+	}, nil
+}
+
+//ResolveBVN returns the cached result for bvn or resolves and caches it
+func (c *CachedBVNResolver) ResolveBVN(bvn string) (ResolveBVN, error) {
+	if err := ValidateBVN(bvn); err != nil {
+		return ResolveBVN{}, err
+	}
+
+	key := c.key(bvn)
+
+	c.mu.Lock()
+	if result, ok := c.get(key); ok {
+		c.stats.Hits++
+		c.mu.Unlock()
+		return c.open(result)
+	}
+
+	if lookup, ok := c.inFlight[key]; ok {
+		c.stats.Shared++
+		c.mu.Unlock()
+		<-lookup.done
+		return lookup.result, lookup.err
+	}
+
+	//The error is replaced by the resolver's answer and only seen by waiters if the resolver panics
+	lookup := &bvnLookup{done: make(chan struct{}), err: errors.New("bvn cache - lookup did not complete")}
+	c.inFlight[key] = lookup
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	//Release the waiters even if the resolver panics
+	defer func() {
+		c.mu.Lock()
+		if c.inFlight[key] == lookup {
+			delete(c.inFlight, key)
+		}
+		c.mu.Unlock()
+		close(lookup.done)
+	}()
+
+	lookup.result, lookup.err = c.resolver.ResolveBVN(bvn)
+
+	var sealed ResolveBVN
+	var sealErr error
+	if lookup.err == nil {
+		sealed, sealErr = c.seal(lookup.result)
+	}
+
+	c.mu.Lock()
+	if lookup.err == nil && sealErr == nil && !lookup.invalidated {
+		c.put(key, sealed)
+	}
+	c.mu.Unlock()
+	return lookup.result, lookup.err
+}
+
+//ResolveBVNDetails is an alias of ResolveBVN, as on the identity service
+func (c *CachedBVNResolver) ResolveBVNDetails(bvn string) (ResolveBVN, error) {
+	return c.ResolveBVN(bvn)
+}
+
+//Invalidate drops the cached result for bvn, e.g. after the user updates their BVN record.
+//A lookup of bvn already in flight is not cached and later lookups do not wait for it.
+func (c *CachedBVNResolver) Invalidate(bvn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.key(bvn)
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	if lookup, ok := c.inFlight[key]; ok {
+		lookup.invalidated = true
+		delete(c.inFlight, key)
+	}
+}
+
+//Purge drops every cached result, including those of lookups in flight. Stats are kept.
+func (c *CachedBVNResolver) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+	for _, lookup := range c.inFlight {
+		lookup.invalidated = true
+	}
+	c.inFlight = map[string]*bvnLookup{}
+}
+
+//Stats returns the lookup counters and the current number of entries
+func (c *CachedBVNResolver) Stats() BVNCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+//HitRatio is the share of lookups answered without calling the resolver
+func (s BVNCacheStats) HitRatio() float64 {
+	total := s.Hits + s.Shared + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.Shared) / float64(total)
+}
+
+//get returns the entry for key, dropping it if it expired. The caller holds c.mu.
+func (c *CachedBVNResolver) get(key string) (ResolveBVN, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return ResolveBVN{}, false
+	}
+
+	entry := element.Value.(*bvnCacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.stats.Expirations++
+		c.remove(element)
+		return ResolveBVN{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.result, true
+}
+
+//put stores result for key, evicting the least recently used entries beyond MaxEntries. The caller holds c.mu.
+func (c *CachedBVNResolver) put(key string, result ResolveBVN) {
+	entry := &bvnCacheEntry{key: key, result: result, expiresAt: c.now().Add(c.options.TTL)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.options.MaxEntries {
+		c.stats.Evictions++
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *CachedBVNResolver) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*bvnCacheEntry).key)
+}
+
+func (c *CachedBVNResolver) seal(result ResolveBVN) (ResolveBVN, error) {
+	if c.options.Encryptor == nil {
+		return result, nil
+	}
+	return c.options.Encryptor.EncryptResolveBVN(result)
+}
+
+func (c *CachedBVNResolver) open(result ResolveBVN) (ResolveBVN, error) {
+	if c.options.Encryptor == nil {
+		return result, nil
+	}
+	return c.options.Encryptor.DecryptResolveBVN(result)
+}
+
+//key hashes bvn with HMAC-SHA256 so the cache does not hold BVNs in the clear, even with encryption enabled.
+//A plain hash of an 11 digit BVN could be reversed by hashing every possible BVN.
+func (c *CachedBVNResolver) key(bvn string) string {
+	mac := hmac.New(sha256.New, c.keyHMAC)
+	mac.Write([]byte(bvn))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,189 @@
+package gowalletsafrica
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+const (
+	PictureFormatJPEG string = "jpeg"
+	PictureFormatPNG  string = "png"
+
+	DefaultMaxPictureBytes     int = 2 << 20
+	DefaultMaxPictureDimension int = 4096
+	DefaultThumbnailSize       int = 160
+)
+
+type (
+	//PictureOptions limits what DecodeBVNPicture accepts. Zero values use the defaults.
+	PictureOptions struct {
+		MaxBytes  int //Size of the decoded file, defaults to DefaultMaxPictureBytes
+		MaxWidth  int //Defaults to DefaultMaxPictureDimension
+		MaxHeight int //Defaults to DefaultMaxPictureDimension
+	}
+)
+
+//DecodePicture decodes Picture with the default limits. It returns the image and its format,
+//PictureFormatJPEG or PictureFormatPNG.
+func (r ResolveBVN) DecodePicture() (image.Image, string, error) {
+	return DecodeBVNPicture(r.Picture, PictureOptions{})
+}
+
+//DecodeBVNPicture decodes a base64 picture as returned in ResolveBVN.Picture, with or without a data URI
+//prefix. The size and dimensions are checked before the pixels are decoded, so an oversized picture is
+//rejected without allocating memory for it.
+func DecodeBVNPicture(picture string, options PictureOptions) (image.Image, string, error) {
+	maxBytes := options.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxPictureBytes
+	}
+
+	maxWidth, maxHeight := options.MaxWidth, options.MaxHeight
+	if maxWidth <= 0 {
+		maxWidth = DefaultMaxPictureDimension
+	}
+	if maxHeight <= 0 {
+		maxHeight = DefaultMaxPictureDimension
+	}
+
+	encoded := picture
+	if strings.HasPrefix(encoded, "data:") {
+		if comma := strings.Index(encoded, ","); comma >= 0 {
+			encoded = encoded[comma+1:]
+		}
+	}
+
+	encoded = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, encoded)
+
+	if encoded == "" {
+		return nil, "", errors.New("BVN picture is empty")
+	}
+
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxBytes+2 {
+		return nil, "", errors.New(fmt.Sprintf("BVN picture is larger than %v bytes", maxBytes))
+	}
+
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, "", errors.New(fmt.Sprintf("BVN picture is not valid base64 - %v", err))
+	}
+
+	if len(raw) > maxBytes {
+		return nil, "", errors.New(fmt.Sprintf("BVN picture is larger than %v bytes", maxBytes))
+	}
+
+	format := pictureFormat(raw)
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
+	switch format {
+	case PictureFormatJPEG:
+		decodeConfig, decode = jpeg.DecodeConfig, jpeg.Decode
+	case PictureFormatPNG:
+		decodeConfig, decode = png.DecodeConfig, png.Decode
+	default:
+		return nil, "", errors.New("BVN picture is not a JPEG or PNG image")
+	}
+
+	config, err := decodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, format, err
+	}
+
+	if config.Width > maxWidth || config.Height > maxHeight {
+		return nil, format, errors.New(fmt.Sprintf("BVN picture is %vx%v, larger than the %vx%v limit", config.Width, config.Height, maxWidth, maxHeight))
+	}
+
+	img, err := decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, format, err
+	}
+	return img, format, nil
+}
+
+//Thumbnail scales img down to fit in a size by size square, keeping its aspect ratio.
+//Every thumbnail pixel averages the source pixels it covers. Smaller images are returned as they are.
+func Thumbnail(img image.Image, size int) image.Image {
+	if size <= 0 {
+		size = DefaultThumbnailSize
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	thumbWidth, thumbHeight := size, size
+	if width > height {
+		thumbHeight = maxInt(1, height*size/width)
+	} else {
+		thumbWidth = maxInt(1, width*size/height)
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for ty := 0; ty < thumbHeight; ty++ {
+		y0, y1 := bounds.Min.Y+ty*height/thumbHeight, bounds.Min.Y+(ty+1)*height/thumbHeight
+		for tx := 0; tx < thumbWidth; tx++ {
+			x0, x1 := bounds.Min.X+tx*width/thumbWidth, bounds.Min.X+(tx+1)*width/thumbWidth
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := img.At(x, y).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+
+			thumbnail.Set(tx, ty, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return thumbnail
+}
+
+//WriteThumbnail writes a thumbnail of img, see Thumbnail, to w as a JPEG
+func WriteThumbnail(w io.Writer, img image.Image, size int) error {
+	return jpeg.Encode(w, Thumbnail(img, size), &jpeg.Options{Quality: 85})
+}
+
+//pictureFormat detects the format from the file signature, since the API does not say which it sent
+func pictureFormat(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xD8, 0xFF}):
+		return PictureFormatJPEG
+	case bytes.HasPrefix(raw, []byte("\x89PNG\r\n\x1a\n")):
+		return PictureFormatPNG
+	}
+	return ""
+}
+
+//decodeBase64 accepts padded and unpadded, standard and URL safe base64
+func decodeBase64(encoded string) ([]byte, error) {
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		var raw []byte
+		if raw, err = encoding.DecodeString(encoded); err == nil {
+			return raw, nil
+		}
+	}
+	return nil, err
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,319 @@
+package gowalletsafrica
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	Camt053Namespace string = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+	camtDateLayout     string = "2006-01-02"
+	camtDateTimeLayout string = "2006-01-02T15:04:05"
+	camtMax35          int    = 35
+	camtMax140         int    = 140
+	camtMax500         int    = 500
+
+	camtCredit        string = "CRDT"
+	camtDebit         string = "DBIT"
+	camtBooked        string = "BOOK"
+	camtOpeningBooked string = "OPBD"
+	camtClosingBooked string = "CLBD"
+
+	//camtNoTransactionCode fills BkTxCd, which the schema requires, when a transaction has no category
+	camtNoTransactionCode string = "NOTPROVIDED"
+)
+
+type (
+	//Camt053Options describes the statement wrapped around the transactions.
+	//From and To are inclusive and compared by date only.
+	Camt053Options struct {
+		MessageID      string
+		StatementID    string
+		AccountID      string
+		AccountName    string
+		Currency       Currency
+		From           time.Time
+		To             time.Time
+		SequenceNumber int
+		CreatedAt      time.Time //Defaults to the current time
+	}
+
+	camtDocument struct {
+		XMLName   xml.Name      `xml:"Document"`
+		Namespace string        `xml:"xmlns,attr"`
+		Statement camtBkToCstmr `xml:"BkToCstmrStmt"`
+	}
+
+	camtBkToCstmr struct {
+		GroupHeader camtGroupHeader `xml:"GrpHdr"`
+		Statement   camtStatement   `xml:"Stmt"`
+	}
+
+	camtGroupHeader struct {
+		MessageID string `xml:"MsgId"`
+		CreatedAt string `xml:"CreDtTm"`
+	}
+
+	camtStatement struct {
+		ID             string        `xml:"Id"`
+		SequenceNumber int           `xml:"ElctrncSeqNb,omitempty"`
+		CreatedAt      string        `xml:"CreDtTm"`
+		Period         camtPeriod    `xml:"FrToDt"`
+		Account        camtAccount   `xml:"Acct"`
+		Balances       []camtBalance `xml:"Bal"`
+		Summary        camtSummary   `xml:"TxsSummry"`
+		Entries        []camtEntry   `xml:"Ntry"`
+	}
+
+	camtPeriod struct {
+		From string `xml:"FrDtTm"`
+		To   string `xml:"ToDtTm"`
+	}
+
+	camtAccount struct {
+		ID       string     `xml:"Id>Othr>Id"`
+		Currency string     `xml:"Ccy"`
+		Owner    *camtOwner `xml:"Ownr,omitempty"`
+	}
+
+	camtOwner struct {
+		Name string `xml:"Nm"`
+	}
+
+	camtAmount struct {
+		Currency string `xml:"Ccy,attr"`
+		Value    string `xml:",chardata"`
+	}
+
+	camtBalance struct {
+		Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+		Amount    camtAmount `xml:"Amt"`
+		Indicator string     `xml:"CdtDbtInd"`
+		Date      string     `xml:"Dt>Dt"`
+	}
+
+	camtSummary struct {
+		Total   camtTotal       `xml:"TtlNtries"`
+		Credits camtTotalByType `xml:"TtlCdtNtries"`
+		Debits  camtTotalByType `xml:"TtlDbtNtries"`
+	}
+
+	camtTotal struct {
+		Count     int    `xml:"NbOfNtries"`
+		Sum       string `xml:"Sum"`
+		Net       string `xml:"TtlNetNtryAmt"`
+		Indicator string `xml:"CdtDbtInd"`
+	}
+
+	camtTotalByType struct {
+		Count int    `xml:"NbOfNtries"`
+		Sum   string `xml:"Sum"`
+	}
+
+	camtEntry struct {
+		Reference     string     `xml:"NtryRef"`
+		Amount        camtAmount `xml:"Amt"`
+		Indicator     string     `xml:"CdtDbtInd"`
+		Status        string     `xml:"Sts"`
+		BookingDate   string     `xml:"BookgDt>DtTm"`
+		ValueDate     string     `xml:"ValDt>Dt"`
+		TransactionCd string     `xml:"BkTxCd>Prtry>Cd"`
+		Unstructured  string     `xml:"NtryDtls>TxDtls>RmtInf>Ustrd,omitempty"`
+		Information   string     `xml:"AddtlNtryInf,omitempty"`
+	}
+)
+
+//GenerateCamt053 builds a camt.053.001.02 statement from the transactions in options.Currency dated
+//between options.From and options.To. Opening and closing balances come from the balance chain of
+//the included entries.
+func GenerateCamt053(transactions Transactions, options Camt053Options) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := WriteCamt053(buffer, transactions, options); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+//WriteCamt053 writes the document produced by GenerateCamt053 to w
+func WriteCamt053(w io.Writer, transactions Transactions, options Camt053Options) error {
+	if err := validateCamt053Options(options); err != nil {
+		return err
+	}
+
+	currency := Currency(strings.ToUpper(string(options.Currency)))
+	from := truncateToDay(options.From)
+	to := truncateToDay(options.To)
+
+	included := Transactions{}
+	for _, t := range transactions {
+		if !strings.EqualFold(t.Currency, string(currency)) {
+			continue
+		}
+
+		date, err := t.Time()
+		if err != nil {
+			return err
+		}
+
+		day := truncateToDay(date)
+		if day.Before(from) || day.After(to) {
+			continue
+		}
+		included = append(included, t)
+	}
+
+	if len(included) == 0 {
+		return errors.New("camt.053 - no transactions in the requested currency and date range")
+	}
+
+	included = SortTransactions(included.Dedup())
+	opening, closing, _ := StatementBalances(included)
+
+	createdAt := options.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	statement := camtStatement{
+		ID:             truncate(options.StatementID, camtMax35),
+		SequenceNumber: options.SequenceNumber,
+		CreatedAt:      createdAt.Format(camtDateTimeLayout),
+		Period: camtPeriod{
+			From: from.Format(camtDateTimeLayout),
+			To:   to.Add(24*time.Hour - time.Second).Format(camtDateTimeLayout),
+		},
+		Account: camtAccount{ID: options.AccountID, Currency: string(currency)},
+		Balances: []camtBalance{
+			newCamtBalance(camtOpeningBooked, opening, currency, from),
+			newCamtBalance(camtClosingBooked, closing, currency, to),
+		},
+	}
+
+	if options.AccountName != "" {
+		statement.Account.Owner = &camtOwner{Name: truncate(options.AccountName, camtMax140)}
+	}
+
+	var sum, credits, debits float64
+	for _, t := range included {
+		date, _ := t.Time()
+		signed, err := t.SignedAmount()
+		if err != nil {
+			return err
+		}
+
+		entry := camtEntry{
+			Reference:     truncate(t.Fingerprint(), camtMax35),
+			Amount:        camtAmount{Currency: string(currency), Value: FormatAmount(math.Abs(signed), currency)},
+			Indicator:     camtCredit,
+			Status:        camtBooked,
+			BookingDate:   date.Format(camtDateTimeLayout),
+			ValueDate:     date.Format(camtDateLayout),
+			TransactionCd: camtTransactionCode(t.Category),
+			Unstructured:  truncate(t.Narration, camtMax140),
+			Information:   truncate(t.Narration, camtMax500),
+		}
+
+		if signed < 0 {
+			entry.Indicator = camtDebit
+			debits += -signed
+			statement.Summary.Debits.Count++
+		} else {
+			credits += signed
+			statement.Summary.Credits.Count++
+		}
+		sum += math.Abs(signed)
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	net := credits - debits
+	statement.Summary.Total = camtTotal{
+		Count:     len(included),
+		Sum:       FormatAmount(sum, currency),
+		Net:       FormatAmount(math.Abs(net), currency),
+		Indicator: camtIndicator(net),
+	}
+	statement.Summary.Credits.Sum = FormatAmount(credits, currency)
+	statement.Summary.Debits.Sum = FormatAmount(debits, currency)
+
+	document := camtDocument{
+		Namespace: Camt053Namespace,
+		Statement: camtBkToCstmr{
+			GroupHeader: camtGroupHeader{
+				MessageID: truncate(options.MessageID, camtMax35),
+				CreatedAt: createdAt.Format(camtDateTimeLayout),
+			},
+			Statement: statement,
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func validateCamt053Options(options Camt053Options) error {
+	if options.MessageID == "" {
+		return errors.New("camt.053 - message id is required")
+	}
+
+	if options.StatementID == "" {
+		return errors.New("camt.053 - statement id is required")
+	}
+
+	if options.AccountID == "" {
+		return errors.New("camt.053 - account id is required")
+	}
+
+	if options.Currency == "" {
+		return errors.New("camt.053 - currency is required")
+	}
+
+	if options.From.IsZero() || options.To.IsZero() || options.To.Before(options.From) {
+		return errors.New(fmt.Sprintf("camt.053 - invalid date range %v to %v", options.From.Format(camtDateLayout), options.To.Format(camtDateLayout)))
+	}
+	return nil
+}
+
+func newCamtBalance(code string, amount float64, currency Currency, date time.Time) camtBalance {
+	return camtBalance{
+		Code:      code,
+		Amount:    camtAmount{Currency: string(currency), Value: FormatAmount(math.Abs(amount), currency)},
+		Indicator: camtIndicator(amount),
+		Date:      date.Format(camtDateLayout),
+	}
+}
+
+func camtIndicator(amount float64) string {
+	if amount < 0 {
+		return camtDebit
+	}
+	return camtCredit
+}
+
+//truncateToDay drops the time of day, keeping the calendar date in UTC like parsed transaction dates
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+//camtTransactionCode returns category as a proprietary code, which must be 1 to 35 characters
+func camtTransactionCode(category string) string {
+	if category = strings.TrimSpace(category); category == "" {
+		return camtNoTransactionCode
+	}
+	return truncate(category, camtMax35)
+}
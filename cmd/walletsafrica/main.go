@@ -0,0 +1,475 @@
+//Command walletsafrica is an operator tool for common Wallets Africa lookups.
+//
+//Usage:
+//
+//	walletsafrica [--env sandbox|live] [--output table|json|csv] <command> [flags]
+//
+//Keys are read from --public-key/--secret-key or the WALLETSAFRICA_PUBLIC_KEY and
+//WALLETSAFRICA_SECRET_KEY environment variables. WALLETSAFRICA_ENV sets the default environment.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jcobhams/gowalletsafrica"
+)
+
+const (
+	OutputTable string = "table"
+	OutputJSON  string = "json"
+	OutputCSV   string = "csv"
+
+	EnvPublicKey   string = "WALLETSAFRICA_PUBLIC_KEY"
+	EnvSecretKey   string = "WALLETSAFRICA_SECRET_KEY"
+	EnvEnvironment string = "WALLETSAFRICA_ENV"
+)
+
+type (
+	options struct {
+		environment string
+		publicKey   string
+		secretKey   string
+		output      string
+		apiURL      string
+		timeout     time.Duration
+	}
+
+	//table is the common shape every command renders; value is what gets printed in JSON mode
+	table struct {
+		headers []string
+		rows    [][]string
+		value   interface{}
+	}
+
+	command struct {
+		name  string
+		usage string
+		run   func(client *gowalletsafrica.WalletsAfrica, args []string) (table, error)
+	}
+)
+
+var commands = []command{
+	{"balance", "balance --currency NGN", runBalance},
+	{"transactions", "transactions --currency NGN [--type all|credit|debit] [--take 50] [--skip 0] [--from YYYY-MM-DD] [--to YYYY-MM-DD]", runTransactions},
+	{"wallets list", "wallets list", runWalletsList},
+	{"wallets generate", "wallets generate --currency NGN --first-name John --last-name Doe --email john@example.com [--dob YYYY-MM-DD]", runWalletsGenerate},
+	{"wallets credit", "wallets credit --phone 0811... --amount 1000 --reference REF", runWalletsCredit},
+	{"banks", "banks", runBanks},
+	{"payout status", "payout status --reference REF [--wait 2m]", runPayoutStatus},
+	{"bvn resolve", "bvn resolve --bvn 22231485915 [--show-pii]", runBVNResolve},
+	{"airtime providers", "airtime providers", runAirtimeProviders},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+//run executes the CLI and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := options{}
+	global := flag.NewFlagSet("walletsafrica", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.StringVar(&opts.environment, "env", envOrDefault(EnvEnvironment, gowalletsafrica.EnvSandbox), "API environment: sandbox or live")
+	global.StringVar(&opts.publicKey, "public-key", os.Getenv(EnvPublicKey), "API public key (defaults to $"+EnvPublicKey+")")
+	global.StringVar(&opts.secretKey, "secret-key", os.Getenv(EnvSecretKey), "API secret key (defaults to $"+EnvSecretKey+")")
+	global.StringVar(&opts.output, "output", OutputTable, "output format: table, json or csv")
+	global.StringVar(&opts.apiURL, "api-url", "", "override the API base url")
+	global.DurationVar(&opts.timeout, "timeout", gowalletsafrica.RequestTimeout, "request timeout")
+	global.Usage = func() { printUsage(stderr, global) }
+
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+
+	cmd, rest, ok := findCommand(global.Args())
+	if !ok {
+		printUsage(stderr, global)
+		return 2
+	}
+
+	if opts.output != OutputTable && opts.output != OutputJSON && opts.output != OutputCSV {
+		fmt.Fprintf(stderr, "unsupported output format %q\n", opts.output)
+		return 2
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	result, err := cmd.run(client, rest)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := render(stdout, opts.output, result); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func newClient(opts options) (*gowalletsafrica.WalletsAfrica, error) {
+	config := gowalletsafrica.Config{
+		Environment:    opts.environment,
+		PublicKey:      opts.publicKey,
+		SecretKey:      opts.secretKey,
+		RequestTimeout: opts.timeout,
+	}
+
+	//The sandbox keys are public, so fall back to them when none were provided
+	if config.Environment == gowalletsafrica.EnvSandbox {
+		if config.PublicKey == "" {
+			config.PublicKey = gowalletsafrica.SandBoxPublicKey
+		}
+		if config.SecretKey == "" {
+			config.SecretKey = gowalletsafrica.SandBoxSecretKey
+		}
+	}
+
+	client, err := gowalletsafrica.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.apiURL != "" {
+		//All services share the same base so setting it once is enough
+		client.Self.APIURL = strings.TrimRight(opts.apiURL, "/")
+	}
+	return client, nil
+}
+
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+
+		matched := true
+		for i, w := range words {
+			if args[i] != w {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+func printUsage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: walletsafrica [global flags] <command> [flags]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %v\n", cmd.usage)
+	}
+	fmt.Fprintln(w, "\nglobal flags:")
+	global.SetOutput(w)
+	global.PrintDefaults()
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func required(name, value string) error {
+	if value == "" {
+		return errors.New(fmt.Sprintf("--%v is required", name))
+	}
+	return nil
+}
+
+func runBalance(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	fs := newFlagSet("balance")
+	currency := fs.String("currency", string(gowalletsafrica.CurrencyNigeria), "wallet currency")
+	if err := fs.Parse(args); err != nil {
+		return table{}, err
+	}
+
+	result, err := client.Self.CheckBalance(gowalletsafrica.Currency(strings.ToUpper(*currency)))
+	if err != nil {
+		return table{}, err
+	}
+
+	return table{
+		headers: []string{"Currency", "Balance"},
+		rows:    [][]string{{result.WalletCurrency, formatAmount(result.WalletBalance)}},
+		value:   result,
+	}, nil
+}
+
+func runTransactions(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	fs := newFlagSet("transactions")
+	currency := fs.String("currency", string(gowalletsafrica.CurrencyNigeria), "wallet currency")
+	kind := fs.String("type", "all", "transaction type: all, credit or debit")
+	take := fs.Int("take", 50, "number of transactions to fetch")
+	skip := fs.Int("skip", 0, "number of transactions to skip")
+	from := fs.String("from", "", "start date (YYYY-MM-DD)")
+	to := fs.String("to", "", "end date (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return table{}, err
+	}
+
+	var transactionType gowalletsafrica.TransactionType
+	switch strings.ToLower(*kind) {
+	case "all":
+		transactionType = gowalletsafrica.TransactionTypeAll
+	case "credit":
+		transactionType = gowalletsafrica.TransactionTypeCredit
+	case "debit":
+		transactionType = gowalletsafrica.TransactionTypeDebit
+	default:
+		return table{}, errors.New(fmt.Sprintf("unsupported transaction type %q", *kind))
+	}
+
+	transactions, err := client.Self.Transactions(gowalletsafrica.Currency(strings.ToUpper(*currency)), transactionType, *take, *skip, *from, *to)
+	if err != nil {
+		return table{}, err
+	}
+
+	t := table{
+		headers: []string{"Date", "Type", "Amount", "Currency", "Category", "Narration", "PreviousBalance", "NewBalance"},
+		value:   transactions,
+	}
+	for _, tx := range transactions {
+		t.rows = append(t.rows, []string{tx.DateTransacted, tx.Type, formatAmount(tx.Amount), tx.Currency, tx.Category, tx.Narration, formatAmount(tx.PreviousBalance), formatAmount(tx.NewBalance)})
+	}
+	return t, nil
+}
+
+func runWalletsList(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	if err := newFlagSet("wallets list").Parse(args); err != nil {
+		return table{}, err
+	}
+
+	wallets, err := client.Self.GetWallets()
+	if err != nil {
+		return table{}, err
+	}
+
+	t := table{
+		headers: []string{"FirstName", "LastName", "Email", "PhoneNumber", "DateCreated"},
+		value:   wallets,
+	}
+	for _, w := range wallets {
+		t.rows = append(t.rows, []string{w.FirstName, w.LastName, w.Email, w.PhoneNumber, w.DateCreated})
+	}
+	return t, nil
+}
+
+func runWalletsGenerate(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	fs := newFlagSet("wallets generate")
+	currency := fs.String("currency", string(gowalletsafrica.CurrencyNigeria), "wallet currency")
+	firstName := fs.String("first-name", "", "first name")
+	lastName := fs.String("last-name", "", "last name")
+	email := fs.String("email", "", "email address")
+	dob := fs.String("dob", "", "date of birth (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return table{}, err
+	}
+
+	if err := required("first-name", *firstName); err != nil {
+		return table{}, err
+	}
+	if err := required("last-name", *lastName); err != nil {
+		return table{}, err
+	}
+	if err := required("email", *email); err != nil {
+		return table{}, err
+	}
+
+	wallet, err := client.Wallets.Generate(gowalletsafrica.Currency(strings.ToUpper(*currency)), *firstName, *lastName, *email, *dob)
+	if err != nil {
+		return table{}, err
+	}
+
+	return table{
+		headers: []string{"AccountName", "AccountNo", "Bank", "PhoneNumber", "Email", "Password"},
+		rows:    [][]string{{wallet.AccountName, wallet.AccountNo, wallet.Bank, wallet.PhoneNumber, wallet.Email, wallet.Password}},
+		value:   wallet,
+	}, nil
+}
+
+func runWalletsCredit(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	fs := newFlagSet("wallets credit")
+	phone := fs.String("phone", "", "wallet phone number")
+	amount := fs.Float64("amount", 0, "amount to credit")
+	reference := fs.String("reference", "", "transaction reference")
+	if err := fs.Parse(args); err != nil {
+		return table{}, err
+	}
+
+	if err := required("phone", *phone); err != nil {
+		return table{}, err
+	}
+	if err := required("reference", *reference); err != nil {
+		return table{}, err
+	}
+	if *amount <= 0 {
+		return table{}, errors.New("--amount must be greater than 0")
+	}
+
+	result, err := client.Wallets.Credit(*amount, *reference, *phone)
+	if err != nil {
+		return table{}, err
+	}
+
+	return table{
+		headers: []string{"AmountCredited", "RecipientWalletBalance", "SenderWalletBalance"},
+		rows:    [][]string{{formatAmount(result.AmountCredited), formatAmount(result.RecipientWalletBalance), formatAmount(result.SenderWalletBalance)}},
+		value:   result,
+	}, nil
+}
+
+func runBanks(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	if err := newFlagSet("banks").Parse(args); err != nil {
+		return table{}, err
+	}
+
+	banks, err := client.Payouts.GetBanks()
+	if err != nil {
+		return table{}, err
+	}
+
+	t := table{headers: []string{"BankCode", "BankName", "BankSortCode"}, value: banks}
+	for _, b := range banks {
+		t.rows = append(t.rows, []string{b.BankCode, b.BankName, b.BankSortCode})
+	}
+	return t, nil
+}
+
+func runPayoutStatus(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	fs := newFlagSet("payout status")
+	reference := fs.String("reference", "", "transaction reference")
+	wait := fs.Duration("wait", 0, "keep polling up to this long until the payout settles")
+	if err := fs.Parse(args); err != nil {
+		return table{}, err
+	}
+
+	if err := required("reference", *reference); err != nil {
+		return table{}, err
+	}
+
+	var detail gowalletsafrica.BankDetail
+	var err error
+	if *wait > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *wait)
+		defer cancel()
+		detail, _, err = client.Payouts.WaitForPayout(ctx, *reference)
+	} else {
+		detail, err = client.Payouts.BankDetails(*reference)
+	}
+	if err != nil {
+		return table{}, err
+	}
+
+	return table{
+		headers: []string{"Status", "Bank", "AccountNumber", "RecipientName", "Amount", "DateTransferred", "ResponseCode", "Message"},
+		rows:    [][]string{{string(detail.Status()), detail.Bank, detail.AccountNumber, detail.RecipientName, formatAmount(detail.Amount), detail.DateTransferred, detail.ResponseCode, detail.Message}},
+		value: struct {
+			gowalletsafrica.BankDetail
+			Status gowalletsafrica.PayoutStatus
+		}{detail, detail.Status()},
+	}, nil
+}
+
+func runBVNResolve(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	fs := newFlagSet("bvn resolve")
+	bvn := fs.String("bvn", "", "bank verification number")
+	showPII := fs.Bool("show-pii", false, "print personal details unmasked")
+	if err := fs.Parse(args); err != nil {
+		return table{}, err
+	}
+
+	if err := required("bvn", *bvn); err != nil {
+		return table{}, err
+	}
+
+	result, err := client.Identity.ResolveBVN(*bvn)
+	if err != nil {
+		return table{}, err
+	}
+
+	if !*showPII {
+		result = result.Redact()
+	}
+
+	return table{
+		headers: []string{"BVN", "FirstName", "MiddleName", "LastName", "DateOfBirth", "PhoneNumber", "Email", "LevelOfAccount", "WatchListed"},
+		rows:    [][]string{{result.BVN, result.FirstName, result.MiddleName, result.LastName, result.DateOfBirth, result.PhoneNumber, result.Email, result.LevelOfAccount, result.WatchListed}},
+		value:   result,
+	}, nil
+}
+
+func runAirtimeProviders(client *gowalletsafrica.WalletsAfrica, args []string) (table, error) {
+	if err := newFlagSet("airtime providers").Parse(args); err != nil {
+		return table{}, err
+	}
+
+	providers, err := client.Airtime.GetProviders()
+	if err != nil {
+		return table{}, err
+	}
+
+	t := table{headers: []string{"Code", "Name"}, value: providers}
+	for _, p := range providers {
+		t.rows = append(t.rows, []string{p.Code, p.Name})
+	}
+	return t, nil
+}
+
+func render(w io.Writer, output string, t table) error {
+	switch output {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t.value)
+	case OutputCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(t.headers); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(t.rows); err != nil {
+			return err
+		}
+		return writer.Error()
+	default:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.Join(t.headers, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	}
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
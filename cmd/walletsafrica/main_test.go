@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mockAPIServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		switch r.URL.Path {
+		case "/self/balance":
+			fmt.Fprint(w, `{"Response": {"ResponseCode": "200","Message": "Balance Retrieved successfully"},"Data": {"WalletBalance": 880.16,"WalletCurrency": "NGN"}}`)
+		case "/account/resolvebvn":
+			fmt.Fprint(w, `{"FirstName": "JOHN","LastName": "DOE","Email": "test@example.com","PhoneNumber": "0706657415","BVN": "22231485915","DateOfBirth": "11-04-1992","ResponseCode": "200","Message": "BVN details retrieved"}`)
+		case "/transfer/banks/all":
+			fmt.Fprint(w, `[{"BankCode": "044","BankName": "Access Bank Nigeria","BankSortCode": "000014","PaymentGateway": null}]`)
+		}
+	}))
+}
+
+func TestRun_Balance(t *testing.T) {
+	server := mockAPIServer()
+	defer server.Close()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"--api-url", server.URL, "balance", "--currency", "ngn"}, stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout.String(), "880.16")
+
+	stdout.Reset()
+	code = run([]string{"--api-url", server.URL, "--output", "json", "balance"}, stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout.String(), `"WalletBalance": 880.16`)
+}
+
+func TestRun_BanksCSV(t *testing.T) {
+	server := mockAPIServer()
+	defer server.Close()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"--api-url", server.URL, "--output", "csv", "banks"}, stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "BankCode,BankName,BankSortCode\n044,Access Bank Nigeria,000014\n", stdout.String())
+}
+
+func TestRun_BVNResolve(t *testing.T) {
+	server := mockAPIServer()
+	defer server.Close()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run([]string{"--api-url", server.URL, "--output", "json", "bvn", "resolve", "--bvn", "22231485915"}, stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout.String(), "*******5915")
+	assert.NotContains(t, stdout.String(), "22231485915")
+	assert.NotContains(t, stdout.String(), "test@example.com")
+
+	stdout.Reset()
+	code = run([]string{"--api-url", server.URL, "bvn", "resolve", "--bvn", "22231485915", "--show-pii"}, stdout, stderr)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout.String(), "22231485915")
+	assert.Contains(t, stdout.String(), "test@example.com")
+}
+
+func TestRun_Errors(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 2, run([]string{"unknown"}, stdout, stderr))
+	assert.Equal(t, 2, run([]string{"--output", "xml", "banks"}, stdout, stderr))
+	assert.Equal(t, 1, run([]string{"--env", "live", "banks"}, stdout, stderr))
+	assert.Equal(t, 1, run([]string{"payout", "status"}, stdout, stderr))
+}
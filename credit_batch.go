@@ -0,0 +1,247 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	//CreditInstruction credits one sub wallet, identified by its phone number
+	CreditInstruction struct {
+		PhoneNumber string  `json:"phone_number"`
+		Amount      float64 `json:"amount"`
+		Reference   string  `json:"reference"`
+	}
+
+	CreditItemResult struct {
+		Instruction            CreditInstruction `json:"instruction"`
+		Status                 BatchItemStatus   `json:"status"`
+		Error                  string            `json:"error,omitempty"`
+		AmountCredited         float64           `json:"amount_credited,omitempty"`
+		RecipientWalletBalance float64           `json:"recipient_wallet_balance,omitempty"`
+		CompletedAt            time.Time         `json:"completed_at"`
+	}
+
+	//WalletCreditor is satisfied by WalletsAfrica.Wallets
+	WalletCreditor interface {
+		Credit(amount float64, transactionReference, phoneNumber string) (CreditWalletResult, error)
+	}
+
+	//CreditBatch credits sub wallets with bounded concurrency and an optional rate limit.
+	//Every instruction is validated before the first credit is sent; with DryRun set nothing is sent.
+	CreditBatch struct {
+		Wallets       WalletCreditor
+		Concurrency   int     //Defaults to DefaultBatchConcurrency
+		RatePerSecond float64 //Zero means unlimited
+		MaxAmount     float64 //Zero means no limit
+		DryRun        bool
+		//AllowRepeats accepts several instructions crediting the same phone number with the same amount.
+		//By default they are all invalid, since a repeated row is more often a mistake than intended.
+		AllowRepeats bool
+	}
+
+	CreditSummary struct {
+		Total          int                `json:"total"`
+		Succeeded      int                `json:"succeeded"`
+		Failed         int                `json:"failed"`
+		Invalid        int                `json:"invalid"`
+		Validated      int                `json:"validated"`
+		AmountCredited float64            `json:"amount_credited"`
+		AmountFailed   float64            `json:"amount_failed"`
+		DryRun         bool               `json:"dry_run"`
+		Results        []CreditItemResult `json:"results"` //In the order of the instructions
+	}
+)
+
+//ReadCreditInstructionsCSV reads instructions from a CSV file with a header row containing
+//phone_number, amount and reference
+func ReadCreditInstructionsCSV(r io.Reader) ([]CreditInstruction, error) {
+	rows, err := readCSVRows(r, "phone_number", "amount", "reference")
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := []CreditInstruction{}
+	for i, row := range rows {
+		amount, err := strconv.ParseFloat(row["amount"], 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("row %v - invalid amount %q", i+2, row["amount"]))
+		}
+		instructions = append(instructions, CreditInstruction{PhoneNumber: row["phone_number"], Amount: amount, Reference: row["reference"]})
+	}
+	return instructions, nil
+}
+
+//ReadCreditInstructionsJSON reads a JSON array of instructions
+func ReadCreditInstructionsJSON(r io.Reader) ([]CreditInstruction, error) {
+	instructions := []CreditInstruction{}
+	if err := json.NewDecoder(r).Decode(&instructions); err != nil {
+		return nil, err
+	}
+	return instructions, nil
+}
+
+//Validate returns a result for every instruction, BatchItemValidated for the ones that may be sent
+//and BatchItemInvalid with the reason for the others. References must be unique within the batch,
+//and so must phone number and amount pairs unless AllowRepeats is set.
+func (b *CreditBatch) Validate(instructions []CreditInstruction) []CreditItemResult {
+	references := map[string]int{}
+	credits := map[string]int{}
+	for _, instruction := range instructions {
+		references[instruction.Reference]++
+		credits[creditKey(instruction)]++
+	}
+
+	results := make([]CreditItemResult, len(instructions))
+	for i, instruction := range instructions {
+		problem := ""
+		switch {
+		case ValidateReference(instruction.Reference) != nil:
+			problem = ValidateReference(instruction.Reference).Error()
+		case references[instruction.Reference] > 1:
+			problem = "transaction reference is used more than once in the batch"
+		case !isPhoneNumber(instruction.PhoneNumber):
+			problem = fmt.Sprintf("invalid phone number %v", instruction.PhoneNumber)
+		case !(instruction.Amount > 0):
+			problem = "amount must be greater than 0"
+		case math.Abs(instruction.Amount*100-math.Round(instruction.Amount*100)) > 1e-6:
+			problem = "amount has more than 2 decimal places"
+		case b.MaxAmount > 0 && instruction.Amount > b.MaxAmount:
+			problem = fmt.Sprintf("amount is above the batch limit of %v", FormatAmount(b.MaxAmount, CurrencyNigeria))
+		case !b.AllowRepeats && credits[creditKey(instruction)] > 1:
+			problem = fmt.Sprintf("%v is credited %v more than once in the batch", instruction.PhoneNumber, FormatAmount(instruction.Amount, CurrencyNigeria))
+		}
+
+		results[i] = CreditItemResult{Instruction: instruction, Status: BatchItemValidated}
+		if problem != "" {
+			results[i].Status, results[i].Error = BatchItemInvalid, problem
+		}
+	}
+	return results
+}
+
+//Run validates every instruction and, unless DryRun is set, credits the valid ones.
+//It stops starting new credits when ctx ends and returns the summary so far with ctx's error;
+//instructions that were not attempted keep the BatchItemValidated status.
+func (b *CreditBatch) Run(ctx context.Context, instructions []CreditInstruction) (CreditSummary, error) {
+	results := b.Validate(instructions)
+	summary := CreditSummary{Total: len(instructions), DryRun: b.DryRun, Results: results}
+
+	var runErr error
+	if !b.DryRun {
+		if b.Wallets == nil {
+			return summary, errors.New("credit batch - wallets service is required")
+		}
+
+		pending := []int{}
+		for i, result := range results {
+			if result.Status == BatchItemValidated {
+				pending = append(pending, i)
+			}
+		}
+
+		concurrency := b.Concurrency
+		if concurrency < 1 {
+			concurrency = DefaultBatchConcurrency
+		}
+
+		mu := sync.Mutex{}
+		runErr = runConcurrently(ctx, len(pending), concurrency, newRateLimiter(b.RatePerSecond), func(i int) {
+			result := b.send(results[pending[i]].Instruction)
+
+			mu.Lock()
+			results[pending[i]] = result
+			mu.Unlock()
+		})
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case BatchItemSucceeded:
+			summary.Succeeded++
+			summary.AmountCredited += result.AmountCredited
+		case BatchItemFailed:
+			summary.Failed++
+			summary.AmountFailed += result.Instruction.Amount
+		case BatchItemInvalid:
+			summary.Invalid++
+		case BatchItemValidated:
+			summary.Validated++
+		}
+	}
+	return summary, runErr
+}
+
+func (b *CreditBatch) send(instruction CreditInstruction) CreditItemResult {
+	result := CreditItemResult{Instruction: instruction}
+	credit, err := b.Wallets.Credit(instruction.Amount, instruction.Reference, instruction.PhoneNumber)
+	result.CompletedAt = time.Now()
+
+	if err != nil {
+		result.Status = BatchItemFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = BatchItemSucceeded
+	result.AmountCredited = credit.AmountCredited
+	result.RecipientWalletBalance = credit.RecipientWalletBalance
+	return result
+}
+
+//WriteJSON writes the summary, including every item result, as a JSON document
+func (s CreditSummary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+//WriteCSV writes one row per item result
+func (s CreditSummary) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"phone_number", "amount", "reference", "status", "error", "amount_credited", "recipient_wallet_balance", "completed_at"})
+	for _, r := range s.Results {
+		completedAt := ""
+		if !r.CompletedAt.IsZero() {
+			completedAt = r.CompletedAt.Format(time.RFC3339)
+		}
+
+		writer.Write([]string{
+			r.Instruction.PhoneNumber, FormatAmount(r.Instruction.Amount, CurrencyNigeria), r.Instruction.Reference,
+			string(r.Status), r.Error, FormatAmount(r.AmountCredited, CurrencyNigeria),
+			FormatAmount(r.RecipientWalletBalance, CurrencyNigeria), completedAt,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//creditKey identifies the credit an instruction makes, so 0803... and +234803... count as the same number
+func creditKey(instruction CreditInstruction) string {
+	return fmt.Sprintf("%v|%v", limitPhoneSubject(instruction.PhoneNumber), FormatAmount(instruction.Amount, CurrencyNigeria))
+}
+
+//isPhoneNumber accepts 10 to 15 digits with an optional leading +. It is deliberately looser than the phone
+//package: the API identifies sub wallets by numbers such as 13267006065 that are not mobile numbers.
+func isPhoneNumber(phoneNumber string) bool {
+	digits := strings.TrimPrefix(phoneNumber, "+")
+	if len(digits) < 10 || len(digits) > 15 {
+		return false
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,526 @@
+package gowalletsafrica
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TransactionDateLayout string = "1/2/2006 3:04:05 PM"
+	ExportDateLayout      string = "2006-01-02 15:04:05"
+	ofxDateLayout         string = "20060102150405"
+	ofxNameMaxLength      int    = 32
+
+	TransactionTypeNameCredit string = "Credit"
+	TransactionTypeNameDebit  string = "Debit"
+
+	ExportColumnDate            ExportColumn = "date"
+	ExportColumnType            ExportColumn = "type"
+	ExportColumnAmount          ExportColumn = "amount"
+	ExportColumnSignedAmount    ExportColumn = "signed_amount"
+	ExportColumnCurrency        ExportColumn = "currency"
+	ExportColumnCategory        ExportColumn = "category"
+	ExportColumnNarration       ExportColumn = "narration"
+	ExportColumnPreviousBalance ExportColumn = "previous_balance"
+	ExportColumnNewBalance      ExportColumn = "new_balance"
+)
+
+//transactionDateLayouts are tried in order when parsing DateTransacted values
+var transactionDateLayouts = []string{
+	TransactionDateLayout,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	DateFormat,
+}
+
+//DefaultExportColumns is used when ExportOptions.Columns is empty
+var DefaultExportColumns = []ExportColumn{
+	ExportColumnDate,
+	ExportColumnType,
+	ExportColumnSignedAmount,
+	ExportColumnCurrency,
+	ExportColumnCategory,
+	ExportColumnNarration,
+	ExportColumnPreviousBalance,
+	ExportColumnNewBalance,
+}
+
+//currencyMinorUnits holds the ISO 4217 number of decimal places for the supported currencies and for
+//African currencies that do not use two. Currencies missing here use two.
+var currencyMinorUnits = map[Currency]int{
+	CurrencyNigeria: 2,
+	CurrencyUSA:     2,
+	CurrencyGhana:   2,
+	CurrencyKenya:   2,
+	"UGX":           0,
+	"RWF":           0,
+	"XOF":           0,
+	"XAF":           0,
+	"GNF":           0,
+	"KMF":           0,
+	"DJF":           0,
+	"TND":           3,
+	"LYD":           3,
+}
+
+type (
+	ExportColumn string
+
+	//TransactionWriter streams transactions into an export format.
+	//Close must be called to flush any buffered output.
+	TransactionWriter interface {
+		Write(transaction Transaction) error
+		Close() error
+	}
+
+	ExportOptions struct {
+		Columns    []ExportColumn
+		DateLayout string //Layout used for the date column, defaults to ExportDateLayout
+
+		//OFX only
+		BankID    string
+		AccountID string
+	}
+
+	csvTransactionWriter struct {
+		writer        *csv.Writer
+		options       ExportOptions
+		headerWritten bool
+	}
+
+	jsonLinesTransactionWriter struct {
+		writer  *bufio.Writer
+		options ExportOptions
+	}
+
+	ofxTransactionWriter struct {
+		writer       io.Writer
+		options      ExportOptions
+		currency     string
+		transactions Transactions
+	}
+)
+
+//Time parses DateTransacted. The API does not send a timezone so the result is in UTC.
+func (t Transaction) Time() (time.Time, error) {
+	return ParseTransactionDate(t.DateTransacted)
+}
+
+//SignedAmount returns Amount as a positive value for credits and a negative value for debits
+func (t Transaction) SignedAmount() (float64, error) {
+	switch strings.ToLower(t.Type) {
+	case strings.ToLower(TransactionTypeNameCredit):
+		return math.Abs(t.Amount), nil
+	case strings.ToLower(TransactionTypeNameDebit):
+		return -math.Abs(t.Amount), nil
+	}
+	return 0, errors.New(fmt.Sprintf("unknown transaction type %q", t.Type))
+}
+
+//ParseTransactionDate parses the date formats returned by the API
+func ParseTransactionDate(value string) (time.Time, error) {
+	for _, layout := range transactionDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("unrecognised transaction date %q", value))
+}
+
+//SortTransactions returns a copy of transactions ordered from oldest to newest.
+//Entries with unparseable dates are kept at the end in their original order.
+func SortTransactions(transactions Transactions) Transactions {
+	sorted := make(Transactions, len(transactions))
+	copy(sorted, transactions)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, erri := sorted[i].Time()
+		tj, errj := sorted[j].Time()
+		if erri != nil || errj != nil {
+			return erri == nil && errj != nil
+		}
+		return ti.Before(tj)
+	})
+	return sorted
+}
+
+//StatementBalances returns the opening balance (PreviousBalance of the oldest entry) and the
+//closing balance (NewBalance of the newest entry) of transactions
+func StatementBalances(transactions Transactions) (opening, closing float64, err error) {
+	if len(transactions) == 0 {
+		return 0, 0, errors.New("no transactions to compute balances from")
+	}
+
+	sorted := SortTransactions(transactions)
+	return sorted[0].PreviousBalance, sorted[len(sorted)-1].NewBalance, nil
+}
+
+//CurrencyMinorUnits returns the number of decimal places used by the currency, e.g. 2 for NGN and 0 for UGX
+func CurrencyMinorUnits(currency Currency) int {
+	if units, ok := currencyMinorUnits[Currency(strings.ToUpper(strings.TrimSpace(string(currency))))]; ok {
+		return units
+	}
+	return 2
+}
+
+//FormatAmount formats amount with the number of decimal places used by the currency
+func FormatAmount(amount float64, currency Currency) string {
+	return strconv.FormatFloat(amount, 'f', CurrencyMinorUnits(currency), 64)
+}
+
+//ExportTransactions writes every distinct transaction to w and closes it.
+//Repeated entries, e.g. from overlapping fetches, are written once.
+func ExportTransactions(w TransactionWriter, transactions Transactions) error {
+	for _, t := range transactions.Dedup() {
+		if err := w.Write(t); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+//NewCSVWriter returns a TransactionWriter producing CSV with a header row of column names
+func NewCSVWriter(w io.Writer, options ExportOptions) (TransactionWriter, error) {
+	options, err := normalizeExportOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return &csvTransactionWriter{writer: csv.NewWriter(w), options: options}, nil
+}
+
+func (c *csvTransactionWriter) Write(transaction Transaction) error {
+	if !c.headerWritten {
+		header := make([]string, len(c.options.Columns))
+		for i, column := range c.options.Columns {
+			header[i] = string(column)
+		}
+		if err := c.writer.Write(header); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	record := make([]string, len(c.options.Columns))
+	for i, column := range c.options.Columns {
+		value, err := exportColumnValue(transaction, column, c.options)
+		if err != nil {
+			return err
+		}
+		record[i] = value
+	}
+	return c.writer.Write(record)
+}
+
+func (c *csvTransactionWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+//NewJSONLinesWriter returns a TransactionWriter producing one JSON object per line.
+//Amount columns are written as JSON numbers with the currency's decimal places.
+func NewJSONLinesWriter(w io.Writer, options ExportOptions) (TransactionWriter, error) {
+	options, err := normalizeExportOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonLinesTransactionWriter{writer: bufio.NewWriter(w), options: options}, nil
+}
+
+func (j *jsonLinesTransactionWriter) Write(transaction Transaction) error {
+	//Build the object by hand so keys keep the configured column order, and only write it
+	//once every column is known so an error never leaves a partial line behind
+	line := bytes.Buffer{}
+	line.WriteByte('{')
+	for i, column := range j.options.Columns {
+		value, err := exportColumnValue(transaction, column, j.options)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			line.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(string(column))
+		line.Write(key)
+		line.WriteByte(':')
+
+		if isAmountColumn(column) {
+			line.WriteString(value)
+		} else {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			line.Write(encoded)
+		}
+	}
+	line.WriteString("}\n")
+
+	_, err := j.writer.Write(line.Bytes())
+	return err
+}
+
+func (j *jsonLinesTransactionWriter) Close() error {
+	return j.writer.Flush()
+}
+
+//NewOFXWriter returns a TransactionWriter producing an OFX 2.x bank statement.
+//OFX needs the statement period and balances up front, so entries are buffered until Close.
+//All transactions must share the same currency.
+func NewOFXWriter(w io.Writer, options ExportOptions) (TransactionWriter, error) {
+	if options.AccountID == "" {
+		return nil, errors.New("ofx export - account id is required")
+	}
+	return &ofxTransactionWriter{writer: w, options: options}, nil
+}
+
+func (o *ofxTransactionWriter) Write(transaction Transaction) error {
+	if o.currency == "" {
+		o.currency = strings.ToUpper(transaction.Currency)
+	}
+
+	if !strings.EqualFold(o.currency, transaction.Currency) {
+		return errors.New(fmt.Sprintf("ofx export - mixed currencies %v and %v in one statement", o.currency, transaction.Currency))
+	}
+
+	if _, err := transaction.Time(); err != nil {
+		return err
+	}
+
+	if _, err := transaction.SignedAmount(); err != nil {
+		return err
+	}
+
+	o.transactions = append(o.transactions, transaction)
+	return nil
+}
+
+func (o *ofxTransactionWriter) Close() error {
+	if len(o.transactions) == 0 {
+		return errors.New("ofx export - no transactions to write")
+	}
+
+	sorted := SortTransactions(o.transactions.Dedup())
+	currency := Currency(o.currency)
+	start, _ := sorted[0].Time()
+	end, _ := sorted[len(sorted)-1].Time()
+	opening, closing, _ := StatementBalances(sorted)
+
+	statement := ofxStatementResponse{
+		Currency: o.currency,
+		Account: ofxBankAccount{
+			BankID:      o.options.BankID,
+			AccountID:   o.options.AccountID,
+			AccountType: "CHECKING",
+		},
+		TransactionList: ofxTransactionList{
+			Start: start.Format(ofxDateLayout),
+			End:   end.Format(ofxDateLayout),
+		},
+		LedgerBalance:    ofxBalance{Amount: FormatAmount(closing, currency), AsOf: end.Format(ofxDateLayout)},
+		AvailableBalance: ofxBalance{Amount: FormatAmount(closing, currency), AsOf: end.Format(ofxDateLayout)},
+		Balances: []ofxNamedBalance{
+			{Name: "Opening balance", Description: "Balance before the first transaction", Type: "DOLLAR", Value: FormatAmount(opening, currency), AsOf: start.Format(ofxDateLayout)},
+			{Name: "Closing balance", Description: "Balance after the last transaction", Type: "DOLLAR", Value: FormatAmount(closing, currency), AsOf: end.Format(ofxDateLayout)},
+		},
+	}
+
+	for _, t := range sorted {
+		posted, _ := t.Time()
+		signed, _ := t.SignedAmount()
+
+		entry := ofxStatementTransaction{
+			Type:   strings.ToUpper(TransactionTypeNameCredit),
+			Posted: posted.Format(ofxDateLayout),
+			Amount: FormatAmount(signed, currency),
+			FITID:  t.Fingerprint(),
+			Name:   truncate(t.Category, ofxNameMaxLength),
+			Memo:   t.Narration,
+		}
+		if signed < 0 {
+			entry.Type = strings.ToUpper(TransactionTypeNameDebit)
+		}
+		statement.TransactionList.Transactions = append(statement.TransactionList.Transactions, entry)
+	}
+
+	now := time.Now().UTC().Format(ofxDateLayout)
+	document := ofxDocument{
+		SignOn: ofxSignOnResponse{
+			Status:   ofxStatus{Code: 0, Severity: "INFO"},
+			Server:   now,
+			Language: "ENG",
+		},
+		Bank: ofxBankResponse{
+			Transaction: ofxStatementTransactionResponse{
+				UID:       "0",
+				Status:    ofxStatus{Code: 0, Severity: "INFO"},
+				Statement: statement,
+			},
+		},
+	}
+
+	if _, err := io.WriteString(o.writer, xml.Header); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(o.writer, `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+"\n"); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(o.writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(o.writer, "\n")
+	return err
+}
+
+type (
+	ofxDocument struct {
+		XMLName xml.Name          `xml:"OFX"`
+		SignOn  ofxSignOnResponse `xml:"SIGNONMSGSRSV1>SONRS"`
+		Bank    ofxBankResponse   `xml:"BANKMSGSRSV1"`
+	}
+
+	ofxSignOnResponse struct {
+		Status   ofxStatus `xml:"STATUS"`
+		Server   string    `xml:"DTSERVER"`
+		Language string    `xml:"LANGUAGE"`
+	}
+
+	ofxStatus struct {
+		Code     int    `xml:"CODE"`
+		Severity string `xml:"SEVERITY"`
+	}
+
+	ofxBankResponse struct {
+		Transaction ofxStatementTransactionResponse `xml:"STMTTRNRS"`
+	}
+
+	ofxStatementTransactionResponse struct {
+		UID       string               `xml:"TRNUID"`
+		Status    ofxStatus            `xml:"STATUS"`
+		Statement ofxStatementResponse `xml:"STMTRS"`
+	}
+
+	ofxStatementResponse struct {
+		Currency         string             `xml:"CURDEF"`
+		Account          ofxBankAccount     `xml:"BANKACCTFROM"`
+		TransactionList  ofxTransactionList `xml:"BANKTRANLIST"`
+		LedgerBalance    ofxBalance         `xml:"LEDGERBAL"`
+		AvailableBalance ofxBalance         `xml:"AVAILBAL"`
+		Balances         []ofxNamedBalance  `xml:"BALLIST>BAL"`
+	}
+
+	ofxBankAccount struct {
+		BankID      string `xml:"BANKID"`
+		AccountID   string `xml:"ACCTID"`
+		AccountType string `xml:"ACCTTYPE"`
+	}
+
+	ofxTransactionList struct {
+		Start        string                    `xml:"DTSTART"`
+		End          string                    `xml:"DTEND"`
+		Transactions []ofxStatementTransaction `xml:"STMTTRN"`
+	}
+
+	ofxStatementTransaction struct {
+		Type   string `xml:"TRNTYPE"`
+		Posted string `xml:"DTPOSTED"`
+		Amount string `xml:"TRNAMT"`
+		FITID  string `xml:"FITID"`
+		Name   string `xml:"NAME,omitempty"`
+		Memo   string `xml:"MEMO,omitempty"`
+	}
+
+	ofxBalance struct {
+		Amount string `xml:"BALAMT"`
+		AsOf   string `xml:"DTASOF"`
+	}
+
+	ofxNamedBalance struct {
+		Name        string `xml:"NAME"`
+		Description string `xml:"DESC"`
+		Type        string `xml:"BALTYPE"`
+		Value       string `xml:"VALUE"`
+		AsOf        string `xml:"DTASOF"`
+	}
+)
+
+func normalizeExportOptions(options ExportOptions) (ExportOptions, error) {
+	if len(options.Columns) == 0 {
+		options.Columns = DefaultExportColumns
+	}
+
+	if options.DateLayout == "" {
+		options.DateLayout = ExportDateLayout
+	}
+
+	for _, column := range options.Columns {
+		switch column {
+		case ExportColumnDate, ExportColumnType, ExportColumnAmount, ExportColumnSignedAmount, ExportColumnCurrency,
+			ExportColumnCategory, ExportColumnNarration, ExportColumnPreviousBalance, ExportColumnNewBalance:
+		default:
+			return options, errors.New(fmt.Sprintf("unknown export column %q", column))
+		}
+	}
+	return options, nil
+}
+
+func exportColumnValue(t Transaction, column ExportColumn, options ExportOptions) (string, error) {
+	currency := Currency(t.Currency)
+	switch column {
+	case ExportColumnDate:
+		date, err := t.Time()
+		if err != nil {
+			return "", err
+		}
+		return date.Format(options.DateLayout), nil
+	case ExportColumnType:
+		return t.Type, nil
+	case ExportColumnAmount:
+		return FormatAmount(t.Amount, currency), nil
+	case ExportColumnSignedAmount:
+		signed, err := t.SignedAmount()
+		if err != nil {
+			return "", err
+		}
+		return FormatAmount(signed, currency), nil
+	case ExportColumnCurrency:
+		return t.Currency, nil
+	case ExportColumnCategory:
+		return t.Category, nil
+	case ExportColumnNarration:
+		return t.Narration, nil
+	case ExportColumnPreviousBalance:
+		return FormatAmount(t.PreviousBalance, currency), nil
+	case ExportColumnNewBalance:
+		return FormatAmount(t.NewBalance, currency), nil
+	}
+	return "", errors.New(fmt.Sprintf("unknown export column %q", column))
+}
+
+func isAmountColumn(column ExportColumn) bool {
+	return column == ExportColumnAmount || column == ExportColumnSignedAmount ||
+		column == ExportColumnPreviousBalance || column == ExportColumnNewBalance
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
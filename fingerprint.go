@@ -0,0 +1,53 @@
+package gowalletsafrica
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+//fingerprintSeparator keeps field boundaries unambiguous, e.g. narration "a|b" vs "a" and "b"
+const fingerprintSeparator = "\x1f"
+
+//Fingerprint returns a deterministic identifier for the transaction, since the API returns none.
+//It hashes the amount, currency, type, narration, timestamp and balance pair, normalised so the
+//same entry fetched twice always yields the same value (amounts in the currency's decimal places,
+//case-insensitive currency and type, parsed timestamps).
+func (t Transaction) Fingerprint() string {
+	currency := Currency(strings.ToUpper(strings.TrimSpace(t.Currency)))
+
+	timestamp := strings.TrimSpace(t.DateTransacted)
+	if date, err := t.Time(); err == nil {
+		timestamp = date.Format(time.RFC3339Nano)
+	}
+
+	fields := []string{
+		FormatAmount(t.Amount, currency),
+		string(currency),
+		strings.ToLower(strings.TrimSpace(t.Type)),
+		strings.TrimSpace(t.Narration),
+		timestamp,
+		FormatAmount(t.PreviousBalance, currency),
+		FormatAmount(t.NewBalance, currency),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, fingerprintSeparator)))
+	return hex.EncodeToString(sum[:])
+}
+
+//Dedup returns the transactions with repeated entries (same Fingerprint) removed, keeping the
+//first occurrence and the original order
+func (ts Transactions) Dedup() Transactions {
+	seen := make(map[string]bool, len(ts))
+	unique := make(Transactions, 0, len(ts))
+	for _, t := range ts {
+		fingerprint := t.Fingerprint()
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		unique = append(unique, t)
+	}
+	return unique
+}
@@ -12,8 +12,8 @@ import (
 //Documentation: https://documenter.getpostman.com/view/10058163/SWLk4RPL?version=latest#86ebd12e-c0e7-4529-86ea-9ed5f6993272
 func (i *identity) ResolveBVN(bvn string) (ResolveBVN, error) {
 	result := ResolveBVN{}
-	if bvn == "" {
-		return result, errors.New("BVN number is required")
+	if err := ValidateBVN(bvn); err != nil {
+		return result, err
 	}
 
 	payloadValues := payloadBody{
@@ -106,3 +106,15 @@ func (i *identity) ResolveBVN(bvn string) (ResolveBVN, error) {
 func (i *identity) ResolveBVNDetails(bvn string) (ResolveBVN, error) {
 	return i.ResolveBVN(bvn)
 }
+
+//ValidateBVN checks that bvn is 11 digits, so typos are caught before calling the API
+func ValidateBVN(bvn string) error {
+	if bvn == "" {
+		return errors.New("BVN number is required")
+	}
+
+	if len(bvn) != 11 || !isDigits(bvn) {
+		return errors.New("BVN number must be 11 digits")
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+package gowalletsafrica
+
+import (
+	"fmt"
+	"github.com/jcobhams/gowalletsafrica/phone"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MatchFieldFirstName   MatchField = "first_name"
+	MatchFieldMiddleName  MatchField = "middle_name"
+	MatchFieldLastName    MatchField = "last_name"
+	MatchFieldDateOfBirth MatchField = "date_of_birth"
+	MatchFieldPhoneNumber MatchField = "phone_number"
+
+	DefaultIdentityMatchThreshold float64 = 0.85
+	DefaultFieldMatchThreshold    float64 = 0.8
+)
+
+//DefaultIdentityMatchWeights weigh each field in the overall score. Fields missing on either side are
+//left out and the remaining weights rescaled.
+var DefaultIdentityMatchWeights = map[MatchField]float64{
+	MatchFieldFirstName:   0.3,
+	MatchFieldMiddleName:  0.1,
+	MatchFieldLastName:    0.3,
+	MatchFieldDateOfBirth: 0.2,
+	MatchFieldPhoneNumber: 0.1,
+}
+
+//IdentityDateLayouts are the date of birth formats the matcher understands, tried in order.
+//The BVN service returns dates as day-month-year, so that reading wins for ambiguous dates.
+var IdentityDateLayouts = []string{
+	"02-01-2006",
+	"2006-01-02",
+	"02/01/2006",
+	"2006/01/02",
+	"02-Jan-2006",
+	"02 Jan 2006",
+	"2 January 2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"02.01.2006",
+}
+
+type (
+	MatchField string
+
+	//IdentityClaim is what the user told us about themselves. Empty fields are not compared.
+	IdentityClaim struct {
+		FirstName   string
+		MiddleName  string
+		LastName    string
+		DateOfBirth string
+		PhoneNumber string
+	}
+
+	//FieldMatch explains how one field compared, for KYC reviewers
+	FieldMatch struct {
+		Field    MatchField
+		Claimed  string
+		Resolved string
+		Score    float64 //0 to 1
+		Matched  bool
+		Compared bool //False when either side is empty
+		Reason   string
+	}
+
+	IdentityMatch struct {
+		Score   float64 //Weighted average of the compared fields, 0 to 1
+		Matched bool
+		Fields  []FieldMatch
+	}
+
+	//IdentityMatcher compares a ResolveBVN result with an IdentityClaim. Names are compared ignoring
+	//case, punctuation, accents and word order, accept initials and tolerate small spelling differences.
+	IdentityMatcher struct {
+		Threshold      float64 //Minimum overall score, defaults to DefaultIdentityMatchThreshold
+		FieldThreshold float64 //Minimum score for a field to count as matched, defaults to DefaultFieldMatchThreshold
+		Weights        map[MatchField]float64
+	}
+)
+
+//MatchIdentity compares resolved with claim using the default thresholds and weights
+func MatchIdentity(resolved ResolveBVN, claim IdentityClaim) IdentityMatch {
+	return (&IdentityMatcher{}).Match(resolved, claim)
+}
+
+//Match compares every field of claim with resolved and returns the overall score with a reason per field.
+//An identity only matches when the score reaches Threshold and both first and last name matched.
+func (m *IdentityMatcher) Match(resolved ResolveBVN, claim IdentityClaim) IdentityMatch {
+	threshold := m.Threshold
+	if threshold <= 0 {
+		threshold = DefaultIdentityMatchThreshold
+	}
+
+	fieldThreshold := m.FieldThreshold
+	if fieldThreshold <= 0 {
+		fieldThreshold = DefaultFieldMatchThreshold
+	}
+
+	weights := m.Weights
+	if weights == nil {
+		weights = DefaultIdentityMatchWeights
+	}
+
+	fields := matchNames(
+		[3]MatchField{MatchFieldFirstName, MatchFieldMiddleName, MatchFieldLastName},
+		[3]string{claim.FirstName, claim.MiddleName, claim.LastName},
+		[3]string{resolved.FirstName, resolved.MiddleName, resolved.LastName},
+		fieldThreshold,
+	)
+	fields = append(fields, matchDateOfBirth(claim.DateOfBirth, resolved.DateOfBirth), matchPhoneNumber(claim.PhoneNumber, resolved.PhoneNumber))
+
+	match := IdentityMatch{Fields: fields}
+	total, weighted := 0.0, 0.0
+	namesMatched := true
+	for i := range fields {
+		field := &fields[i]
+		field.Matched = field.Compared && field.Score >= fieldThreshold
+		if !field.Compared {
+			continue
+		}
+
+		total += weights[field.Field]
+		weighted += weights[field.Field] * field.Score
+		if (field.Field == MatchFieldFirstName || field.Field == MatchFieldLastName) && !field.Matched {
+			namesMatched = false
+		}
+	}
+
+	if total > 0 {
+		match.Score = weighted / total
+	}
+
+	//A claim without both names cannot match, however well the other fields do
+	namesMatched = namesMatched && fields[0].Compared && fields[2].Compared
+	match.Matched = namesMatched && match.Score >= threshold
+	return match
+}
+
+//Field returns the result for one field
+func (m IdentityMatch) Field(field MatchField) FieldMatch {
+	for _, f := range m.Fields {
+		if f.Field == field {
+			return f
+		}
+	}
+	return FieldMatch{Field: field}
+}
+
+//nameOrders lists every way to pair the claimed first, middle and last names with the resolved ones, as
+//the index of the resolved name for each claimed name. The order as given comes first so it wins ties.
+var nameOrders = [][3]int{{0, 1, 2}, {2, 1, 0}, {1, 0, 2}, {0, 2, 1}, {1, 2, 0}, {2, 0, 1}}
+
+//matchNames compares the claimed first, middle and last names with the resolved ones. Names given in another
+//order still count, but each resolved name can only be matched by one claimed name, so "Doe Doe" does not
+//match JOHN DOE and a first and last name only count as swapped when both are.
+func matchNames(fields [3]MatchField, claimed, resolved [3]string, threshold float64) []FieldMatch {
+	var best []FieldMatch
+	bestTotal := -1.0
+	for _, order := range nameOrders {
+		matches, total, usable := make([]FieldMatch, 3), 0.0, true
+		for i, j := range order {
+			//Moving a claimed name onto a name missing from the BVN would only hide it from the comparison
+			if i != j && len(nameWords(claimed[i])) > 0 && len(nameWords(resolved[j])) == 0 {
+				usable = false
+				break
+			}
+			matches[i] = matchName(fields[i], claimed[i], resolved[i], resolved[j], threshold)
+			total += matches[i].Score
+		}
+
+		if usable && total > bestTotal+0.000001 {
+			best, bestTotal = matches, total
+		}
+	}
+	return best
+}
+
+//matchName compares a claimed name with the resolved one, or with another resolved name (compared) when the
+//claim gives the names in a different order. Names may have several words ("Mary Jane") in either order.
+func matchName(field MatchField, claimed, resolved, compared string, threshold float64) FieldMatch {
+	match := FieldMatch{Field: field, Claimed: claimed, Resolved: resolved}
+	claimedWords, comparedWords := nameWords(claimed), nameWords(compared)
+	if len(claimedWords) == 0 || len(comparedWords) == 0 {
+		match.Reason = "not provided"
+		return match
+	}
+
+	match.Compared = true
+	swapped := compared != resolved
+	if !swapped && strings.EqualFold(strings.TrimSpace(claimed), strings.TrimSpace(resolved)) {
+		match.Score, match.Reason = 1, "exact match"
+		return match
+	}
+
+	match.Score, match.Reason = compareNameWords(claimedWords, comparedWords, threshold)
+	//Swapped first and last names are common on forms, so they count almost as much as a match
+	if swapped {
+		match.Score *= 0.9
+		if match.Score >= threshold {
+			match.Reason = fmt.Sprintf("matches another name on the BVN (%v)", compared)
+		} else {
+			match.Reason = "does not match"
+		}
+	}
+	return match
+}
+
+//compareNameWords scores how well every claimed word is found among the resolved words
+func compareNameWords(claimed, resolved []string, threshold float64) (float64, string) {
+	total := 0.0
+	initials, fuzzy, ordered := false, false, true
+	last := -1
+	for _, word := range claimed {
+		best, bestIndex, bestInitial := 0.0, -1, false
+		for i, candidate := range resolved {
+			score, initial := 0.0, false
+			switch {
+			case word == candidate:
+				score = 1
+			case len(word) == 1 && strings.HasPrefix(candidate, word), len(candidate) == 1 && strings.HasPrefix(word, candidate):
+				score, initial = 0.85, true
+			case !withinNameEdits(word, candidate):
+				//Jaro-Winkler rates "joan" and "john" or "ada" and "ade" above the threshold, but a changed
+				//letter in a short name usually makes a different name rather than a typo
+				score = 0
+			default:
+				score = jaroWinkler(word, candidate)
+			}
+
+			if score > best {
+				best, bestIndex, bestInitial = score, i, initial
+			}
+		}
+
+		if bestInitial {
+			initials = true
+		} else if best < 1 {
+			fuzzy = true
+		}
+
+		if bestIndex < last {
+			ordered = false
+		}
+		last = bestIndex
+		total += best
+	}
+
+	score := total / float64(len(claimed))
+	//Words on the BVN the claim leaves out lower the score a little, e.g. a dropped second surname
+	if len(resolved) > len(claimed) {
+		score *= 1 - 0.1*float64(len(resolved)-len(claimed))
+	}
+
+	reasons := []string{}
+	switch {
+	case score < threshold:
+		return score, "does not match"
+	case !initials && !fuzzy:
+		reasons = append(reasons, "matches ignoring case, accents and punctuation")
+	case initials:
+		reasons = append(reasons, "matches by initial")
+	}
+
+	if fuzzy {
+		reasons = append(reasons, fmt.Sprintf("similar spelling (%.2f)", score))
+	}
+
+	if !ordered {
+		reasons = append(reasons, "words in a different order")
+	}
+	return score, strings.Join(reasons, ", ")
+}
+
+func matchDateOfBirth(claimed, resolved string) FieldMatch {
+	match := FieldMatch{Field: MatchFieldDateOfBirth, Claimed: claimed, Resolved: resolved}
+	if strings.TrimSpace(claimed) == "" || strings.TrimSpace(resolved) == "" {
+		match.Reason = "not provided"
+		return match
+	}
+
+	match.Compared = true
+	claimedDate, claimedOK := parseIdentityDate(claimed)
+	resolvedDate, resolvedOK := parseIdentityDate(resolved)
+	switch {
+	case !claimedOK || !resolvedOK:
+		match.Reason = "date could not be read"
+	case claimedDate.Equal(resolvedDate):
+		match.Score, match.Reason = 1, "same date"
+	case claimedDate.Year() == resolvedDate.Year() && claimedDate.Day() == int(resolvedDate.Month()) && int(claimedDate.Month()) == resolvedDate.Day():
+		match.Score, match.Reason = 0.5, "day and month swapped"
+	default:
+		match.Reason = "different date"
+	}
+	return match
+}
+
+func matchPhoneNumber(claimed, resolved string) FieldMatch {
+	match := FieldMatch{Field: MatchFieldPhoneNumber, Claimed: claimed, Resolved: resolved}
+	if strings.TrimSpace(claimed) == "" || strings.TrimSpace(resolved) == "" {
+		match.Reason = "not provided"
+		return match
+	}
+
+	match.Compared = true
+	claimedNumber, claimedErr := phone.Normalize(claimed)
+	resolvedNumber, resolvedErr := phone.Normalize(resolved)
+	if claimedErr != nil || resolvedErr != nil {
+		//Numbers on older BVN records are not always valid today; fall back to the digits themselves
+		claimedNumber, resolvedNumber = subscriberDigits(claimed), subscriberDigits(resolved)
+	}
+
+	if claimedNumber == resolvedNumber {
+		match.Score, match.Reason = 1, "same number"
+		return match
+	}
+	match.Reason = "different number"
+	return match
+}
+
+func parseIdentityDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range IdentityDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+//nameWords lower cases name, folds accented letters to their base letter and splits it into words
+func nameWords(name string) []string {
+	folded := strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		//Tone marks written as combining characters, common in Yoruba names, are dropped
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+
+		if base, ok := foldedLetters[r]; ok {
+			return base
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		//Apostrophes join names such as O'Neil rather than split them
+		if r == '\'' || r == '’' {
+			return -1
+		}
+		return ' '
+	}, name)
+	return strings.Fields(folded)
+}
+
+//subscriberDigits strips everything but digits and then the Nigerian calling code or trunk 0
+func subscriberDigits(value string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+	return strings.TrimPrefix(strings.TrimPrefix(digits, "234"), "0")
+}
+
+//withinNameEdits reports whether a and b differ by few enough edits to be spellings of one name. A dropped,
+//added or swapped letter costs 1 and a changed letter 2. One such edit is allowed per 4 letters of the longer
+//word and always at least 1, so "jon" and "jhon" still match "john" but "joan" does not.
+func withinNameEdits(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+
+	allowed := longest / 4
+	if allowed < 1 {
+		allowed = 1
+	}
+	return nameEditDistance(ra, rb) <= allowed
+}
+
+//nameEditDistance is the optimal string alignment distance of a and b with changed letters costing 2
+func nameEditDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			change := 2
+			if a[i-1] == b[j-1] {
+				change = 0
+			}
+
+			best := d[i-1][j-1] + change
+			if d[i-1][j]+1 < best {
+				best = d[i-1][j] + 1
+			}
+			if d[i][j-1]+1 < best {
+				best = d[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < best {
+				best = d[i-2][j-2] + 1
+			}
+			d[i][j] = best
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+//jaroWinkler is the Jaro-Winkler similarity of a and b. Unlike an edit distance it stays high for short
+//names with one typo or transposed letters, e.g. "jon" and "john" or "jhon" and "john".
+func jaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := len(ra)
+	if len(rb) > window {
+		window = len(rb)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matchedA, matchedB := make([]bool, len(ra)), make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := i - window; j <= i+window; j++ {
+			if j >= 0 && j < len(rb) && !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+//foldedLetters maps accented letters found in West and East African names to their base letter
+var foldedLetters = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ẹ': 'e', 'ɛ': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ō': 'o', 'ọ': 'o', 'ɔ': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u',
+	'ñ': 'n', 'ń': 'n', 'ǹ': 'n',
+	'ṣ': 's', 'ş': 's', 'š': 's',
+	'ç': 'c', 'ý': 'y', 'ÿ': 'y',
+}
@@ -0,0 +1,191 @@
+package gowalletsafrica
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	KYCTier0 KYCTier = 0 //No verified identity
+	KYCTier1 KYCTier = 1
+	KYCTier2 KYCTier = 2
+	KYCTier3 KYCTier = 3
+
+	KYCAllow  KYCOutcome = "allow"
+	KYCReview KYCOutcome = "review"
+	KYCDeny   KYCOutcome = "deny"
+
+	//Unlimited disables a TierLimits field
+	Unlimited float64 = -1
+)
+
+type (
+	KYCTier    int
+	KYCOutcome string
+
+	//TierLimits are in the wallet currency. Use Unlimited to disable a limit; 0 allows nothing.
+	TierLimits struct {
+		MaxBalance      float64
+		MaxSingleCredit float64
+		DailyPayoutCap  float64
+	}
+
+	//KYCPolicy maps ResolveBVN results to tiers and decides whether a credit or payout may go ahead.
+	//Watchlisted BVNs, from the BVN service or from Watchlist, are always denied.
+	KYCPolicy struct {
+		Tiers       map[KYCTier]TierLimits
+		DefaultTier KYCTier //Used, with a review reason, when LevelOfAccount is empty or unreadable
+		//ReviewThreshold flags amounts that use more than this share of a limit for review, e.g. 0.9.
+		//Zero disables reviews for amounts.
+		ReviewThreshold float64
+		Watchlist       map[string]bool //BVNs blocked by us, in addition to the WatchListed flag
+	}
+
+	KYCDecision struct {
+		Outcome KYCOutcome
+		Tier    KYCTier
+		Reasons []string
+	}
+)
+
+//NewDefaultKYCPolicy returns a policy with the CBN three tier KYC limits for NGN accounts. Amounts within 10%
+//of a limit are flagged for review and results with an unreadable watchlist flag are sent to review. Results
+//whose account level cannot be read get KYCTier0, which allows nothing.
+func NewDefaultKYCPolicy() KYCPolicy {
+	return KYCPolicy{
+		Tiers: map[KYCTier]TierLimits{
+			KYCTier0: {MaxBalance: 0, MaxSingleCredit: 0, DailyPayoutCap: 0},
+			KYCTier1: {MaxBalance: 300000, MaxSingleCredit: 50000, DailyPayoutCap: 50000},
+			KYCTier2: {MaxBalance: 500000, MaxSingleCredit: 100000, DailyPayoutCap: 200000},
+			KYCTier3: {MaxBalance: Unlimited, MaxSingleCredit: 5000000, DailyPayoutCap: 5000000},
+		},
+		DefaultTier:     KYCTier0,
+		ReviewThreshold: 0.9,
+	}
+}
+
+//Tier reads the tier from LevelOfAccount, e.g. "Level 2 - Medium Level Accounts" is KYCTier2.
+//Results without a BVN are KYCTier0.
+func (p KYCPolicy) Tier(r ResolveBVN) KYCTier {
+	tier, _ := p.readTier(r)
+	return tier
+}
+
+//readTier returns the tier and whether it was read from LevelOfAccount rather than defaulted
+func (p KYCPolicy) readTier(r ResolveBVN) (KYCTier, bool) {
+	if r.BVN == "" {
+		return KYCTier0, true
+	}
+
+	level := strings.ToLower(r.LevelOfAccount)
+	if index := strings.Index(level, "level"); index >= 0 {
+		rest := strings.TrimSpace(level[index+len("level"):])
+		if rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			tier := KYCTier(rest[0] - '0')
+			if _, ok := p.Tiers[tier]; ok {
+				return tier, true
+			}
+		}
+	}
+	return p.DefaultTier, false
+}
+
+//EvaluateCredit decides whether the wallet of r may be credited with amount given its current balance
+func (p KYCPolicy) EvaluateCredit(r ResolveBVN, amount, balance float64) KYCDecision {
+	decision, limits := p.screen(r)
+	if decision.Outcome == KYCDeny {
+		return decision
+	}
+
+	decision.check("single credit", amount, limits.MaxSingleCredit, p.ReviewThreshold)
+	decision.check("balance after credit", balance+amount, limits.MaxBalance, p.ReviewThreshold)
+	return decision
+}
+
+//EvaluatePayout decides whether r may pay out amount given what they have already paid out today
+func (p KYCPolicy) EvaluatePayout(r ResolveBVN, amount, paidToday float64) KYCDecision {
+	decision, limits := p.screen(r)
+	if decision.Outcome == KYCDeny {
+		return decision
+	}
+
+	decision.check("daily payouts", paidToday+amount, limits.DailyPayoutCap, p.ReviewThreshold)
+	return decision
+}
+
+//Allowed reports whether the operation may go ahead without review
+func (d KYCDecision) Allowed() bool {
+	return d.Outcome == KYCAllow
+}
+
+//Err returns nil when the operation is allowed, and an error listing the reasons otherwise
+func (d KYCDecision) Err() error {
+	if d.Allowed() {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("kyc %v (tier %v) - %v", d.Outcome, d.Tier, strings.Join(d.Reasons, "; ")))
+}
+
+//screen applies the checks common to every operation: watchlists and the tier lookup
+func (p KYCPolicy) screen(r ResolveBVN) (KYCDecision, TierLimits) {
+	tier, read := p.readTier(r)
+	decision := KYCDecision{Outcome: KYCAllow, Tier: tier, Reasons: []string{}}
+	if !read {
+		decision.review(fmt.Sprintf("account level %q could not be read, using tier %v", r.LevelOfAccount, tier))
+	}
+
+	if p.Watchlist[r.BVN] {
+		decision.deny("BVN is on the internal watchlist")
+		return decision, TierLimits{}
+	}
+
+	switch listed, known := parseWatchListed(r.WatchListed); {
+	case listed:
+		decision.deny("BVN is watchlisted")
+		return decision, TierLimits{}
+	case !known:
+		decision.review(fmt.Sprintf("watchlist flag %q could not be read", r.WatchListed))
+	}
+
+	limits, ok := p.Tiers[tier]
+	if !ok {
+		decision.deny(fmt.Sprintf("no limits configured for tier %v", tier))
+	}
+	return decision, limits
+}
+
+//check compares value with limit, denying above it and flagging for review above the review threshold
+func (d *KYCDecision) check(name string, value, limit, reviewThreshold float64) {
+	switch {
+	case limit == Unlimited:
+	case value > limit:
+		d.deny(fmt.Sprintf("%v of %v exceeds the tier %v limit of %v", name, FormatAmount(value, CurrencyNigeria), d.Tier, FormatAmount(limit, CurrencyNigeria)))
+	case reviewThreshold > 0 && value > limit*reviewThreshold:
+		d.review(fmt.Sprintf("%v of %v is close to the tier %v limit of %v", name, FormatAmount(value, CurrencyNigeria), d.Tier, FormatAmount(limit, CurrencyNigeria)))
+	}
+}
+
+func (d *KYCDecision) deny(reason string) {
+	d.Outcome = KYCDeny
+	d.Reasons = append(d.Reasons, reason)
+}
+
+func (d *KYCDecision) review(reason string) {
+	if d.Outcome == KYCAllow {
+		d.Outcome = KYCReview
+	}
+	d.Reasons = append(d.Reasons, reason)
+}
+
+//parseWatchListed reads the WatchListed flag. An empty flag means not listed; a value that is not
+//recognised is reported as unknown rather than guessed.
+func parseWatchListed(value string) (listed bool, known bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "no", "n", "0", "null":
+		return false, true
+	case "true", "yes", "y", "1":
+		return true, true
+	}
+	return false, false
+}
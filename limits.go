@@ -0,0 +1,317 @@
+package gowalletsafrica
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jcobhams/gowalletsafrica/phone"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	LimitOperationCredit LimitOperation = "credit"
+	LimitOperationPayout LimitOperation = "payout"
+
+	DefaultCounterRetention time.Duration = 7 * 24 * time.Hour
+)
+
+type (
+	LimitOperation string
+
+	//LimitRule caps how often and how much an operation may happen for one subject within a sliding
+	//Window. Credits are counted per phone number, payouts per destination bank account.
+	//A zero MaxCount or MaxAmount leaves that side unchecked.
+	LimitRule struct {
+		Name      string
+		Operation LimitOperation
+		Window    time.Duration
+		MaxCount  int
+		MaxAmount float64
+	}
+
+	//CounterEvent is one counted operation
+	CounterEvent struct {
+		ID     string
+		Time   time.Time
+		Amount float64
+	}
+
+	//CounterStore keeps counted events per key. A Limiter checks and records under its own lock, so limits
+	//hold within one process. A store shared between processes, e.g. backed by Redis or SQL, counts their
+	//operations together, but concurrent operations in different processes can pass a rule together.
+	CounterStore interface {
+		Record(key string, event CounterEvent) error
+		Cancel(key string, id string) error
+		Events(key string, since time.Time) ([]CounterEvent, error)
+	}
+
+	//LimitExceededError is returned when an operation would break a LimitRule. No API call was made.
+	LimitExceededError struct {
+		Rule      LimitRule
+		Subject   string
+		Count     int     //Operations already in the window
+		Sum       float64 //Amount already in the window
+		Attempted float64
+	}
+
+	//Limiter checks operations against Rules and records the ones that go ahead
+	Limiter struct {
+		Store CounterStore
+		Rules []LimitRule
+
+		mu  sync.Mutex
+		now func() time.Time
+	}
+
+	//LimitedWallets enforces credit limits before calling Wallets.Credit. It satisfies WalletCreditor,
+	//so it can be used by CreditBatch.
+	LimitedWallets struct {
+		Wallets WalletCreditor
+		Limiter *Limiter
+	}
+
+	//LimitedPayouts enforces payout limits before calling Payouts.BankTransfer. It satisfies
+	//PayoutService, so it can be used by PayoutBatch.
+	LimitedPayouts struct {
+		Payouts PayoutService
+		Limiter *Limiter
+	}
+
+	//MemoryCounterStore keeps events in memory for Retention, which must cover the longest rule window
+	MemoryCounterStore struct {
+		Retention time.Duration //Defaults to DefaultCounterRetention
+
+		mu     sync.Mutex
+		events map[string][]CounterEvent
+	}
+)
+
+//NewLimiter creates a limiter enforcing rules with counters kept in store
+func NewLimiter(store CounterStore, rules ...LimitRule) *Limiter {
+	return &Limiter{Store: store, Rules: rules, now: time.Now}
+}
+
+func (e *LimitExceededError) Error() string {
+	if e.Rule.MaxCount > 0 && e.Count+1 > e.Rule.MaxCount {
+		return fmt.Sprintf("limit %v exceeded for %v - %v %v operations in %v already, the limit is %v",
+			e.Rule.Name, e.Subject, e.Count, e.Rule.Operation, e.Rule.Window, e.Rule.MaxCount)
+	}
+	return fmt.Sprintf("limit %v exceeded for %v - %v would bring the %v total in %v to %v, the limit is %v",
+		e.Rule.Name, e.Subject, FormatAmount(e.Attempted, CurrencyNigeria), e.Rule.Operation, e.Rule.Window,
+		FormatAmount(e.Sum+e.Attempted, CurrencyNigeria), FormatAmount(e.Rule.MaxAmount, CurrencyNigeria))
+}
+
+//Check returns a *LimitExceededError if amount for subject would break a rule, without recording anything
+func (l *Limiter) Check(operation LimitOperation, subject string, amount float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.check(operation, subject, amount, l.clock())
+}
+
+//Reserve checks amount for subject and records it under id if every rule allows it.
+//Call Release with the same id if the operation does not happen after all.
+func (l *Limiter) Reserve(operation LimitOperation, subject, id string, amount float64) error {
+	if l.Store == nil {
+		return errors.New("limiter - counter store is required")
+	}
+
+	//Release finds reservations by id, so an empty one could cancel another reservation
+	if id == "" {
+		return errors.New("limiter - reservation id is required")
+	}
+
+	//Checking and recording under one lock stops concurrent operations from slipping past a rule together
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	if err := l.check(operation, subject, amount, now); err != nil {
+		return err
+	}
+	return l.Store.Record(limitKey(operation, subject), CounterEvent{ID: id, Time: now, Amount: amount})
+}
+
+//Release removes a reservation made with Reserve
+func (l *Limiter) Release(operation LimitOperation, subject, id string) error {
+	return l.Store.Cancel(limitKey(operation, subject), id)
+}
+
+//releaseRejected releases the reservation of an operation that failed with err without effect and returns err,
+//noting when the release failed and the operation keeps counting towards the limits
+func (l *Limiter) releaseRejected(operation LimitOperation, subject, id string, err error) error {
+	if releaseErr := l.Release(operation, subject, id); releaseErr != nil {
+		return errors.New(fmt.Sprintf("%v - limit reservation %v could not be released - %v", err, id, releaseErr))
+	}
+	return err
+}
+
+func (l *Limiter) check(operation LimitOperation, subject string, amount float64, now time.Time) error {
+	var events []CounterEvent
+	var loaded time.Duration
+	for _, rule := range l.Rules {
+		if rule.Operation != operation || rule.Window <= 0 {
+			continue
+		}
+
+		if rule.Window > loaded {
+			var err error
+			if events, err = l.Store.Events(limitKey(operation, subject), now.Add(-rule.Window)); err != nil {
+				return err
+			}
+			loaded = rule.Window
+		}
+
+		count, sum := 0, 0.0
+		since := now.Add(-rule.Window)
+		for _, event := range events {
+			if event.Time.After(since) {
+				count++
+				sum += event.Amount
+			}
+		}
+
+		exceeded := (rule.MaxCount > 0 && count+1 > rule.MaxCount) || (rule.MaxAmount > 0 && sum+amount > rule.MaxAmount+0.000001)
+		if exceeded {
+			return &LimitExceededError{Rule: rule, Subject: subject, Count: count, Sum: sum, Attempted: amount}
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) clock() time.Time {
+	if l.now == nil {
+		return time.Now()
+	}
+	return l.now()
+}
+
+//Credit checks the credit limits for phoneNumber and credits the wallet if they allow it.
+//A credit that is never sent or that the API rejects does not count towards the limits. One that fails without
+//an answer from the API, e.g. on a timeout, still counts since the wallet may have been credited.
+func (w *LimitedWallets) Credit(amount float64, transactionReference, phoneNumber string) (CreditWalletResult, error) {
+	if transactionReference == "" {
+		return CreditWalletResult{}, errors.New("transaction reference is required")
+	}
+
+	subject := limitPhoneSubject(phoneNumber)
+	if err := w.Limiter.Reserve(LimitOperationCredit, subject, transactionReference, amount); err != nil {
+		return CreditWalletResult{}, err
+	}
+
+	result, err := w.Wallets.Credit(amount, transactionReference, phoneNumber)
+	if err != nil && failedWithoutEffect(err) {
+		err = w.Limiter.releaseRejected(LimitOperationCredit, subject, transactionReference, err)
+	}
+	return result, err
+}
+
+func (p *LimitedPayouts) GetBanks() (Banks, error) {
+	return p.Payouts.GetBanks()
+}
+
+//BankTransfer checks the payout limits for the destination account and makes the transfer if they allow it.
+//A transfer that is never sent or that the API rejects does not count towards the limits. One that fails without
+//an answer from the API, e.g. on a timeout, still counts since the money may have moved.
+func (p *LimitedPayouts) BankTransfer(amount float64, bankCode, accountNumber, accountName, narration, transactionReference string) (BankTransferResult, error) {
+	if transactionReference == "" {
+		return BankTransferResult{}, errors.New("transaction reference is required")
+	}
+
+	subject := fmt.Sprintf("%v/%v", bankCode, accountNumber)
+	if err := p.Limiter.Reserve(LimitOperationPayout, subject, transactionReference, amount); err != nil {
+		return BankTransferResult{}, err
+	}
+
+	result, err := p.Payouts.BankTransfer(amount, bankCode, accountNumber, accountName, narration, transactionReference)
+	if err != nil && failedWithoutEffect(err) {
+		err = p.Limiter.releaseRejected(LimitOperationPayout, subject, transactionReference, err)
+	}
+	return result, err
+}
+
+//NewMemoryCounterStore creates an empty in-memory store keeping events for retention
+func NewMemoryCounterStore(retention time.Duration) *MemoryCounterStore {
+	return &MemoryCounterStore{Retention: retention, events: map[string][]CounterEvent{}}
+}
+
+func (m *MemoryCounterStore) Record(key string, event CounterEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = map[string][]CounterEvent{}
+	}
+
+	retention := m.Retention
+	if retention <= 0 {
+		retention = DefaultCounterRetention
+	}
+
+	//Drop events nobody can ask for any more, keeping the list sorted by time
+	events := m.events[key]
+	cutoff := event.Time.Add(-retention)
+	first := sort.Search(len(events), func(i int) bool { return events[i].Time.After(cutoff) })
+	events = append(events[first:], event)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	m.events[key] = events
+	return nil
+}
+
+func (m *MemoryCounterStore) Cancel(key string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := m.events[key]
+	for i, event := range events {
+		if event.ID == id {
+			m.events[key] = append(events[:i:i], events[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCounterStore) Events(key string, since time.Time) ([]CounterEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := []CounterEvent{}
+	for _, event := range m.events[key] {
+		if event.Time.After(since) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func limitKey(operation LimitOperation, subject string) string {
+	return fmt.Sprintf("%v:%v", operation, subject)
+}
+
+//failedWithoutEffect reports whether err shows the operation did not happen: its request was never sent
+//or the API rejected it
+func failedWithoutEffect(err error) bool {
+	var notSentErr *RequestNotSentError
+	return errors.As(err, &notSentErr) || rejectedByAPI(err)
+}
+
+//rejectedByAPI reports whether err is the API refusing a request, so no money moved. Errors without an
+//API error code, e.g. network errors and timeouts, and 408 and 5xx codes leave the outcome unknown.
+func rejectedByAPI(err error) bool {
+	match := apiErrorCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return false
+	}
+
+	code, _ := strconv.Atoi(match[1])
+	return code != 408 && (code < 500 || code > 599)
+}
+
+//limitPhoneSubject normalizes phone numbers so 0803..., +234803... and 234803... share one counter
+func limitPhoneSubject(phoneNumber string) string {
+	if normalized, err := phone.Normalize(phoneNumber); err == nil {
+		return normalized
+	}
+	return phoneNumber
+}
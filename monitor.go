@@ -0,0 +1,161 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	BalanceAlertLow       BalanceAlertKind = "low"
+	BalanceAlertRecovered BalanceAlertKind = "recovered"
+
+	DefaultMonitorInterval time.Duration = time.Minute
+)
+
+type (
+	BalanceAlertKind string
+
+	//BalanceThreshold fires a low alert when the balance drops below Low and a recovered alert once
+	//it climbs back to Recover or above. Keeping Recover above Low stops a balance hovering around the
+	//threshold from flapping. Recover defaults to Low.
+	BalanceThreshold struct {
+		Low     float64
+		Recover float64
+	}
+
+	BalanceAlert struct {
+		Kind      BalanceAlertKind
+		Currency  Currency
+		Balance   float64
+		Threshold float64 //Low for low alerts, Recover for recovered alerts
+		Time      time.Time
+	}
+
+	//BalanceMonitor polls the balance of every currency in Thresholds and reports threshold crossings
+	//to OnAlert and/or the Alerts channel. Failed polls are reported to OnError and do not change state.
+	BalanceMonitor struct {
+		Checker    BalanceChecker
+		Interval   time.Duration
+		Thresholds map[Currency]BalanceThreshold
+		OnAlert    func(alert BalanceAlert)
+		Alerts     chan<- BalanceAlert
+		OnError    func(currency Currency, err error)
+
+		mu  sync.Mutex
+		low map[Currency]bool
+	}
+)
+
+//NewBalanceMonitor creates a monitor polling checker every interval
+func NewBalanceMonitor(checker BalanceChecker, interval time.Duration, thresholds map[Currency]BalanceThreshold) *BalanceMonitor {
+	return &BalanceMonitor{Checker: checker, Interval: interval, Thresholds: thresholds}
+}
+
+//Run polls immediately and then every Interval until ctx is cancelled, returning ctx's error
+func (m *BalanceMonitor) Run(ctx context.Context) error {
+	if m.Checker == nil {
+		return errors.New("balance monitor - checker is required")
+	}
+
+	for currency, threshold := range m.Thresholds {
+		if threshold.Recover != 0 && threshold.Recover < threshold.Low {
+			return errors.New(fmt.Sprintf("balance monitor - %v recover threshold is below the low threshold", currency))
+		}
+	}
+
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+//Check polls every monitored currency once and fires alerts for threshold crossings
+func (m *BalanceMonitor) Check(ctx context.Context) {
+	currencies := make([]Currency, 0, len(m.Thresholds))
+	for currency := range m.Thresholds {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+
+	for _, currency := range currencies {
+		if ctx.Err() != nil {
+			return
+		}
+
+		balance, err := m.Checker.CheckBalance(currency)
+		if err != nil {
+			if m.OnError != nil {
+				m.OnError(currency, err)
+			}
+			continue
+		}
+
+		if alert, ok := m.observe(currency, balance.WalletBalance); ok {
+			m.fire(ctx, alert)
+		}
+	}
+}
+
+//IsLow reports whether currency is currently considered below its threshold
+func (m *BalanceMonitor) IsLow(currency Currency) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.low[currency]
+}
+
+func (m *BalanceMonitor) observe(currency Currency, balance float64) (BalanceAlert, bool) {
+	threshold := m.Thresholds[currency]
+	recoverAt := threshold.Recover
+	if recoverAt == 0 {
+		recoverAt = threshold.Low
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.low == nil {
+		m.low = map[Currency]bool{}
+	}
+
+	alert := BalanceAlert{Currency: currency, Balance: balance, Time: time.Now()}
+	switch {
+	case !m.low[currency] && balance < threshold.Low:
+		m.low[currency] = true
+		alert.Kind, alert.Threshold = BalanceAlertLow, threshold.Low
+		return alert, true
+	case m.low[currency] && balance >= recoverAt:
+		m.low[currency] = false
+		alert.Kind, alert.Threshold = BalanceAlertRecovered, recoverAt
+		return alert, true
+	}
+	return alert, false
+}
+
+func (m *BalanceMonitor) fire(ctx context.Context, alert BalanceAlert) {
+	if m.OnAlert != nil {
+		m.OnAlert(alert)
+	}
+
+	if m.Alerts != nil {
+		select {
+		case m.Alerts <- alert:
+		case <-ctx.Done():
+		}
+	}
+}
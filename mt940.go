@@ -0,0 +1,236 @@
+package gowalletsafrica
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	MT940DefaultEntriesPerPage int = 100
+
+	mt940DateLayout        string = "060102"
+	mt940EntryDateLayout   string = "0102"
+	mt940LineBreak         string = "\r\n"
+	mt940PageSeparator     string = "-"
+	mt940MaxReference      int    = 16
+	mt940MaxAccount        int    = 35
+	mt940MaxAmountLength   int    = 15
+	mt940NarrativeLine     int    = 65
+	mt940NarrativeLines    int    = 6
+	mt940MaxPageSequence   int    = 99999
+	mt940NoReference       string = "NONREF"
+	mt940TypeTransfer      string = "NTRF"
+	mt940TypeMiscellaneous string = "NMSC"
+)
+
+type (
+	//MT940Options describes the statement written by WriteMT940.
+	//Only transactions in Currency are included.
+	MT940Options struct {
+		TransactionReference string //:20: field, at most 16 characters
+		AccountID            string //:25: field
+		StatementNumber      int    //:28C: statement number
+		Currency             Currency
+		MaxEntriesPerPage    int //Defaults to MT940DefaultEntriesPerPage
+	}
+)
+
+//WriteMT940 writes transactions as a SWIFT MT940 customer statement.
+//Statements with more than MaxEntriesPerPage entries are split into pages sharing the statement
+//number, with intermediate (:60M:/:62M:) balances between pages. Pages are separated by a `-` line.
+func WriteMT940(w io.Writer, transactions Transactions, options MT940Options) error {
+	if err := validateMT940Options(options); err != nil {
+		return err
+	}
+
+	currency := Currency(strings.ToUpper(string(options.Currency)))
+	included := Transactions{}
+	for _, t := range transactions {
+		if !strings.EqualFold(t.Currency, string(currency)) {
+			continue
+		}
+
+		if _, err := t.Time(); err != nil {
+			return err
+		}
+
+		if _, err := t.SignedAmount(); err != nil {
+			return err
+		}
+		included = append(included, t)
+	}
+
+	if len(included) == 0 {
+		return errors.New("mt940 - no transactions in the requested currency")
+	}
+	included = SortTransactions(included.Dedup())
+
+	perPage := options.MaxEntriesPerPage
+	if perPage < 1 {
+		perPage = MT940DefaultEntriesPerPage
+	}
+
+	pages := (len(included) + perPage - 1) / perPage
+	if pages > mt940MaxPageSequence {
+		return errors.New(fmt.Sprintf("mt940 - statement needs %v pages, more than the %v allowed", pages, mt940MaxPageSequence))
+	}
+
+	for page := 0; page < pages; page++ {
+		start := page * perPage
+		end := start + perPage
+		if end > len(included) {
+			end = len(included)
+		}
+		entries := included[start:end]
+
+		openingTag, closingTag := "60M", "62M"
+		if page == 0 {
+			openingTag = "60F"
+		}
+		if page == pages-1 {
+			closingTag = "62F"
+		}
+
+		opening, err := mt940Balance(entries[0].PreviousBalance, currency, entries[0])
+		if err != nil {
+			return err
+		}
+
+		closing, err := mt940Balance(entries[len(entries)-1].NewBalance, currency, entries[len(entries)-1])
+		if err != nil {
+			return err
+		}
+
+		lines := []string{
+			":20:" + mt940Text(options.TransactionReference, mt940MaxReference),
+			":25:" + mt940Text(options.AccountID, mt940MaxAccount),
+			fmt.Sprintf(":28C:%v/%v", options.StatementNumber, page+1),
+			":" + openingTag + ":" + opening,
+		}
+
+		for _, t := range entries {
+			statementLine, err := mt940StatementLine(t, currency)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, statementLine)
+
+			if narrative := mt940Narrative(t); narrative != "" {
+				lines = append(lines, ":86:"+narrative)
+			}
+		}
+		lines = append(lines, ":"+closingTag+":"+closing, mt940PageSeparator)
+
+		if _, err := io.WriteString(w, strings.Join(lines, mt940LineBreak)+mt940LineBreak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//FormatMT940Amount formats an amount the SWIFT way: no sign, no thousand separators and a comma
+//as decimal mark, e.g. 1250,50
+func FormatMT940Amount(amount float64, currency Currency) (string, error) {
+	formatted := strings.Replace(FormatAmount(math.Abs(amount), currency), ".", ",", 1)
+	if len(formatted) > mt940MaxAmountLength {
+		return "", errors.New(fmt.Sprintf("mt940 - amount %v is longer than %v characters", formatted, mt940MaxAmountLength))
+	}
+	return formatted, nil
+}
+
+func validateMT940Options(options MT940Options) error {
+	if options.TransactionReference == "" {
+		return errors.New("mt940 - transaction reference is required")
+	}
+
+	if options.AccountID == "" {
+		return errors.New("mt940 - account id is required")
+	}
+
+	if options.Currency == "" {
+		return errors.New("mt940 - currency is required")
+	}
+
+	if options.StatementNumber < 1 || options.StatementNumber > mt940MaxPageSequence {
+		return errors.New(fmt.Sprintf("mt940 - statement number must be between 1 and %v", mt940MaxPageSequence))
+	}
+	return nil
+}
+
+//mt940Balance formats the value of a :60a:/:62a: field, dated on the day of t
+func mt940Balance(balance float64, currency Currency, t Transaction) (string, error) {
+	date, _ := t.Time()
+	amount, err := FormatMT940Amount(balance, currency)
+	if err != nil {
+		return "", err
+	}
+
+	mark := "C"
+	if balance < 0 {
+		mark = "D"
+	}
+	return mark + date.Format(mt940DateLayout) + string(currency) + amount, nil
+}
+
+func mt940StatementLine(t Transaction, currency Currency) (string, error) {
+	date, _ := t.Time()
+	signed, _ := t.SignedAmount()
+	amount, err := FormatMT940Amount(signed, currency)
+	if err != nil {
+		return "", err
+	}
+
+	mark := "C"
+	if signed < 0 {
+		mark = "D"
+	}
+
+	transactionType := mt940TypeMiscellaneous
+	if strings.Contains(strings.ToLower(t.Category), "transfer") {
+		transactionType = mt940TypeTransfer
+	}
+
+	return ":61:" + date.Format(mt940DateLayout) + date.Format(mt940EntryDateLayout) + mark + amount + transactionType +
+		mt940NoReference + "//" + truncate(t.Fingerprint(), mt940MaxReference), nil
+}
+
+//mt940Narrative builds the :86: field from the category and narration, wrapped to 6 lines of 65 characters
+func mt940Narrative(t Transaction) string {
+	text := mt940Text(strings.TrimSpace(strings.Join([]string{t.Category, t.Narration}, " ")), mt940NarrativeLine*mt940NarrativeLines)
+	if text == "" {
+		return ""
+	}
+
+	lines := []string{}
+	for i := 0; text != ""; i++ {
+		n := mt940NarrativeLine
+		if len(text) < n {
+			n = len(text)
+		}
+
+		line := text[:n]
+		if i > 0 && (line[0] == ':' || line[0] == '-') {
+			line = "." + line[1:]
+		}
+		lines = append(lines, line)
+		text = text[n:]
+	}
+	return strings.Join(lines, mt940LineBreak)
+}
+
+//mt940Text replaces characters outside the SWIFT X character set and truncates to length
+func mt940Text(value string, length int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if isAlphanumeric(r) || strings.ContainsRune("/-?:().,'+ ", r) {
+			return r
+		}
+		return '.'
+	}, value)
+
+	//A field line may not start with ':' or '-' as those mark new fields and message ends
+	cleaned = strings.TrimLeft(cleaned, ":-")
+	return truncate(cleaned, length)
+}
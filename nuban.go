@@ -0,0 +1,132 @@
+package gowalletsafrica
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//nubanWeights are the CBN weights applied to the 6 digit institution code followed by the 9 digit serial number
+var nubanWeights = []int{3, 7, 3, 3, 7, 3, 3, 7, 3, 3, 7, 3, 3, 7, 3}
+
+//NUBANCheckDigit computes the CBN check digit for a 9 digit serial number at the institution identified by
+//code. Deposit money banks use their 3 digit bank code, other institutions a 6 digit code; 3 digit codes
+//are padded with "000" as the CBN specifies.
+func NUBANCheckDigit(code, serial string) (int, error) {
+	if !isDigits(code) || (len(code) != 3 && len(code) != 6) {
+		return 0, errors.New(fmt.Sprintf("institution code %v is not 3 or 6 digits", code))
+	}
+
+	if !isDigits(serial) || len(serial) != 9 {
+		return 0, errors.New(fmt.Sprintf("serial number %v is not 9 digits", serial))
+	}
+
+	digits := strings.Repeat("0", 6-len(code)) + code + serial
+	sum := 0
+	for i, weight := range nubanWeights {
+		sum += int(digits[i]-'0') * weight
+	}
+	return (10 - sum%10) % 10, nil
+}
+
+//NUBANCodes returns the institution codes of bank that account numbers may be checked against:
+//BankCode, padded to 6 digits, when it is 3 or 6 digits. BankSortCode is a NIP routing code, not a
+//NUBAN institution code, so it is not used.
+func NUBANCodes(bank Bank) []string {
+	code := bank.BankCode
+	if !isDigits(code) || (len(code) != 3 && len(code) != 6) {
+		return []string{}
+	}
+
+	if len(code) == 3 {
+		code = "000" + code
+	}
+	return []string{code}
+}
+
+//ValidateNUBAN checks that accountNumber is a 10 digit NUBAN whose check digit matches one of the
+//NUBANCodes of bank. Banks without a numeric code cannot be checked and only get the format check.
+func ValidateNUBAN(bank Bank, accountNumber string) error {
+	if !isNUBANFormat(accountNumber) {
+		return errors.New(fmt.Sprintf("account number %v is not 10 digits", accountNumber))
+	}
+
+	codes := NUBANCodes(bank)
+	if len(codes) == 0 {
+		return nil
+	}
+
+	for _, code := range codes {
+		digit, _ := NUBANCheckDigit(code, accountNumber[:9])
+		if digit == int(accountNumber[9]-'0') {
+			return nil
+		}
+	}
+
+	name := bank.BankName
+	if name == "" {
+		name = fmt.Sprintf("bank code %v", bank.BankCode)
+	}
+	return errors.New(fmt.Sprintf("account number %v is not valid for %v", accountNumber, name))
+}
+
+//SuggestBanks returns the banks accountNumber passes the NUBAN check for, in the order of banks.
+//Expect several results: one in ten banks matches any given account number by chance.
+func SuggestBanks(banks Banks, accountNumber string) Banks {
+	suggestions := Banks{}
+	if !isNUBANFormat(accountNumber) {
+		return suggestions
+	}
+
+	for _, bank := range banks {
+		if len(NUBANCodes(bank)) > 0 && ValidateNUBAN(bank, accountNumber) == nil {
+			suggestions = append(suggestions, bank)
+		}
+	}
+	return suggestions
+}
+
+//SuggestBanks is SuggestBanks over the directory's bank list
+func (d *BankDirectory) SuggestBanks(accountNumber string) (Banks, error) {
+	banks, err := d.Banks()
+	if err != nil {
+		return nil, err
+	}
+	return SuggestBanks(banks, accountNumber), nil
+}
+
+//ValidateAccount checks accountNumber with ValidateNUBAN against the bank with bankCode in the directory.
+//It always checks the check digit; see Config.ValidateAccountNumbers for banks where that fails.
+func (d *BankDirectory) ValidateAccount(bankCode, accountNumber string) error {
+	bank, ok, err := d.ByCode(bankCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown bank code %v", bankCode))
+	}
+	return ValidateNUBAN(bank, accountNumber)
+}
+
+//checkAccountNumber checks accountNumber with ValidateNUBAN when checkDigit is set and only its format
+//otherwise, for banks whose API code is not their NUBAN code
+func checkAccountNumber(bank Bank, accountNumber string, checkDigit bool) error {
+	if !checkDigit {
+		bank = Bank{}
+	}
+	return ValidateNUBAN(bank, accountNumber)
+}
+
+//isNUBANFormat reports whether account is a 10 digit NUBAN account number
+func isNUBANFormat(account string) bool {
+	return len(account) == 10 && isDigits(account)
+}
+
+func isDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
@@ -0,0 +1,393 @@
+package gowalletsafrica
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	//PayoutInstruction is one bank transfer in a batch. Reference must be unique within the batch and
+	//stable across runs for a batch to be resumable.
+	PayoutInstruction struct {
+		Reference     string  `json:"reference"`
+		BankCode      string  `json:"bank_code"`
+		AccountNumber string  `json:"account_number"`
+		AccountName   string  `json:"account_name"`
+		Amount        float64 `json:"amount"`
+		Narration     string  `json:"narration"`
+	}
+
+	PayoutItemResult struct {
+		Instruction  PayoutInstruction `json:"instruction"`
+		Status       BatchItemStatus   `json:"status"`
+		Error        string            `json:"error,omitempty"`
+		ResponseCode string            `json:"response_code,omitempty"`
+		Message      string            `json:"message,omitempty"`
+		CompletedAt  time.Time         `json:"completed_at"`
+	}
+
+	//PayoutService is satisfied by WalletsAfrica.Payouts
+	PayoutService interface {
+		GetBanks() (Banks, error)
+		BankTransfer(amount float64, bankCode, accountNumber, accountName, narration, transactionReference string) (BankTransferResult, error)
+	}
+
+	//BatchStore records the outcome of every attempted item so an interrupted batch can be resumed
+	BatchStore interface {
+		LoadResults(batchID string) ([]PayoutItemResult, error)
+		SaveResult(batchID string, result PayoutItemResult) error
+	}
+
+	//PayoutBatch runs payout instructions with bounded concurrency and an optional rate limit.
+	//Items already recorded in Store for the batch ID are skipped, so running the same batch again
+	//only sends what is left. Failed items are not retried unless RetryFailed is set, because a
+	//failure such as a timeout does not prove the transfer was not made.
+	PayoutBatch struct {
+		ID            string
+		Payouts       PayoutService
+		Store         BatchStore
+		Concurrency   int     //Defaults to DefaultBatchConcurrency
+		RatePerSecond float64 //Zero means unlimited
+		RetryFailed   bool
+		//ValidateAccountNumbers checks account numbers with ValidateNUBAN, see Config.ValidateAccountNumbers.
+		//Without it only the 10 digit format is checked.
+		ValidateAccountNumbers bool
+	}
+
+	BatchSummary struct {
+		BatchID         string             `json:"batch_id"`
+		Total           int                `json:"total"`
+		Succeeded       int                `json:"succeeded"`
+		Failed          int                `json:"failed"`
+		Invalid         int                `json:"invalid"`
+		Skipped         int                `json:"skipped"` //Completed in a previous run
+		AmountSucceeded float64            `json:"amount_succeeded"`
+		AmountFailed    float64            `json:"amount_failed"`
+		Results         []PayoutItemResult `json:"results"`
+	}
+
+	//MemoryBatchStore keeps batch results in memory
+	MemoryBatchStore struct {
+		mu      sync.Mutex
+		results map[string][]PayoutItemResult
+	}
+
+	//FileBatchStore appends results as JSON lines to one file per batch in Dir
+	FileBatchStore struct {
+		Dir string
+		mu  sync.Mutex
+	}
+)
+
+//ReadPayoutInstructionsCSV reads instructions from a CSV file with a header row containing
+//reference, bank_code, account_number, account_name, amount and (optionally) narration
+func ReadPayoutInstructionsCSV(r io.Reader) ([]PayoutInstruction, error) {
+	rows, err := readCSVRows(r, "reference", "bank_code", "account_number", "account_name", "amount")
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := []PayoutInstruction{}
+	for i, row := range rows {
+		amount, err := strconv.ParseFloat(row["amount"], 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("row %v - invalid amount %q", i+2, row["amount"]))
+		}
+
+		instructions = append(instructions, PayoutInstruction{
+			Reference:     row["reference"],
+			BankCode:      row["bank_code"],
+			AccountNumber: row["account_number"],
+			AccountName:   row["account_name"],
+			Amount:        amount,
+			Narration:     row["narration"],
+		})
+	}
+	return instructions, nil
+}
+
+//AssignReferences gives every instruction without a reference one from generator.
+//Persist the result before running the batch, otherwise a resumed run cannot recognise them.
+func AssignReferences(instructions []PayoutInstruction, generator *ReferenceGenerator, purpose string) ([]PayoutInstruction, error) {
+	assigned := make([]PayoutInstruction, len(instructions))
+	for i, instruction := range instructions {
+		if instruction.Reference == "" {
+			reference, err := generator.Generate(purpose)
+			if err != nil {
+				return nil, err
+			}
+			instruction.Reference = reference
+		}
+		assigned[i] = instruction
+	}
+	return assigned, nil
+}
+
+//Validate checks every instruction against the bank list from GetBanks and the batch as a whole.
+//It returns the instructions that may be sent and a result for each one that may not.
+func (b *PayoutBatch) Validate(instructions []PayoutInstruction) ([]PayoutInstruction, []PayoutItemResult, error) {
+	if b.Payouts == nil {
+		return nil, nil, errors.New("payout batch - payouts service is required")
+	}
+
+	banks, err := b.Payouts.GetBanks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bankCodes := map[string]Bank{}
+	for _, bank := range banks {
+		bankCodes[bank.BankCode] = bank
+	}
+
+	references := map[string]int{}
+	for _, instruction := range instructions {
+		references[instruction.Reference]++
+	}
+
+	valid := []PayoutInstruction{}
+	invalid := []PayoutItemResult{}
+	for _, instruction := range instructions {
+		problem := ""
+		bank, known := bankCodes[instruction.BankCode]
+		accountErr := checkAccountNumber(bank, instruction.AccountNumber, b.ValidateAccountNumbers)
+		switch {
+		case ValidateReference(instruction.Reference) != nil:
+			problem = ValidateReference(instruction.Reference).Error()
+		case references[instruction.Reference] > 1:
+			problem = "transaction reference is used more than once in the batch"
+		case !known:
+			problem = fmt.Sprintf("unknown bank code %v", instruction.BankCode)
+		case accountErr != nil:
+			problem = accountErr.Error()
+		case strings.TrimSpace(instruction.AccountName) == "":
+			problem = "account name is required"
+		case !(instruction.Amount > 0):
+			problem = "amount must be greater than 0"
+		}
+
+		if problem != "" {
+			invalid = append(invalid, PayoutItemResult{Instruction: instruction, Status: BatchItemInvalid, Error: problem, CompletedAt: time.Now()})
+			continue
+		}
+		valid = append(valid, instruction)
+	}
+	return valid, invalid, nil
+}
+
+//Run validates the instructions and sends the ones not completed in a previous run of the batch.
+//It stops starting new transfers when ctx ends and returns the summary so far with ctx's error.
+func (b *PayoutBatch) Run(ctx context.Context, instructions []PayoutInstruction) (BatchSummary, error) {
+	summary := BatchSummary{BatchID: b.ID, Total: len(instructions), Results: []PayoutItemResult{}}
+	if b.ID == "" || b.Store == nil {
+		return summary, errors.New("payout batch - id and store are required")
+	}
+
+	valid, invalid, err := b.Validate(instructions)
+	if err != nil {
+		return summary, err
+	}
+
+	previous, err := b.Store.LoadResults(b.ID)
+	if err != nil {
+		return summary, err
+	}
+
+	done := map[string]PayoutItemResult{}
+	for _, result := range previous {
+		if result.Status == BatchItemSucceeded || (result.Status == BatchItemFailed && !b.RetryFailed) {
+			done[result.Instruction.Reference] = result
+		}
+	}
+
+	pending := []PayoutInstruction{}
+	for _, instruction := range valid {
+		if result, ok := done[instruction.Reference]; ok {
+			summary.Skipped++
+			summary.add(result)
+			continue
+		}
+		pending = append(pending, instruction)
+	}
+
+	for _, result := range invalid {
+		summary.add(result)
+	}
+
+	concurrency := b.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	mu := sync.Mutex{}
+	var storeErr error
+	runErr := runConcurrently(ctx, len(pending), concurrency, newRateLimiter(b.RatePerSecond), func(i int) {
+		result := b.send(pending[i])
+		err := b.Store.SaveResult(b.ID, result)
+
+		mu.Lock()
+		defer mu.Unlock()
+		summary.add(result)
+		if err != nil && storeErr == nil {
+			storeErr = err
+		}
+	})
+
+	if storeErr != nil {
+		return summary, storeErr
+	}
+	return summary, runErr
+}
+
+func (b *PayoutBatch) send(instruction PayoutInstruction) PayoutItemResult {
+	result := PayoutItemResult{Instruction: instruction}
+	transfer, err := b.Payouts.BankTransfer(instruction.Amount, instruction.BankCode, instruction.AccountNumber, instruction.AccountName, instruction.Narration, instruction.Reference)
+	result.CompletedAt = time.Now()
+	result.ResponseCode = transfer.ResponseCode
+	result.Message = transfer.Message
+
+	if err != nil {
+		result.Status = BatchItemFailed
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = BatchItemSucceeded
+	return result
+}
+
+func (s *BatchSummary) add(result PayoutItemResult) {
+	switch result.Status {
+	case BatchItemSucceeded:
+		s.Succeeded++
+		s.AmountSucceeded += result.Instruction.Amount
+	case BatchItemFailed:
+		s.Failed++
+		s.AmountFailed += result.Instruction.Amount
+	case BatchItemInvalid:
+		s.Invalid++
+	}
+	s.Results = append(s.Results, result)
+}
+
+//WriteJSON writes the summary, including every item result, as a JSON document
+func (s BatchSummary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+//WriteCSV writes one row per item result
+func (s BatchSummary) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"reference", "bank_code", "account_number", "account_name", "amount", "status", "error", "response_code", "message", "completed_at"})
+	for _, r := range s.Results {
+		writer.Write([]string{
+			r.Instruction.Reference, r.Instruction.BankCode, r.Instruction.AccountNumber, r.Instruction.AccountName,
+			FormatAmount(r.Instruction.Amount, CurrencyNigeria), string(r.Status), r.Error, r.ResponseCode, r.Message,
+			r.CompletedAt.Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//NewMemoryBatchStore creates an empty in-memory store
+func NewMemoryBatchStore() *MemoryBatchStore {
+	return &MemoryBatchStore{results: map[string][]PayoutItemResult{}}
+}
+
+func (m *MemoryBatchStore) LoadResults(batchID string) ([]PayoutItemResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]PayoutItemResult(nil), m.results[batchID]...), nil
+}
+
+func (m *MemoryBatchStore) SaveResult(batchID string, result PayoutItemResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[batchID] = append(m.results[batchID], result)
+	return nil
+}
+
+//LoadResults reads the batch file; later lines win when a reference appears more than once
+func (f *FileBatchStore) LoadResults(batchID string) ([]PayoutItemResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path(batchID))
+	if os.IsNotExist(err) {
+		return []PayoutItemResult{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	latest := map[string]int{}
+	results := []PayoutItemResult{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		result := PayoutItemResult{}
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			//A crash can leave a partial last line; that item is simply attempted again
+			continue
+		}
+
+		if i, ok := latest[result.Instruction.Reference]; ok {
+			results[i] = result
+			continue
+		}
+		latest[result.Instruction.Reference] = len(results)
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+func (f *FileBatchStore) SaveResult(batchID string, result PayoutItemResult) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path(batchID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(raw, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *FileBatchStore) path(batchID string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("batch-%v.jsonl", filepath.Base(batchID)))
+}
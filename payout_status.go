@@ -0,0 +1,224 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PayoutStatusPending    PayoutStatus = "pending"
+	PayoutStatusSuccessful PayoutStatus = "successful"
+	PayoutStatusFailed     PayoutStatus = "failed"
+	PayoutStatusReversed   PayoutStatus = "reversed"
+	PayoutStatusUnknown    PayoutStatus = "unknown" //The response code is not in PayoutStatusCodes
+
+	DefaultPayoutPollInterval    time.Duration = 2 * time.Second
+	DefaultPayoutPollMaxInterval time.Duration = 30 * time.Second
+	DefaultPayoutPollMultiplier  float64       = 2
+	DefaultPayoutPollMaxAttempts int           = 40
+)
+
+//PayoutStatusCodes maps BankDetail.ResponseCode values to a status. The API answers with HTTP style
+//codes and passes NIBSS NIP codes through for bank transfers. Other codes are PayoutStatusUnknown.
+var PayoutStatusCodes = map[string]PayoutStatus{
+	"200": PayoutStatusSuccessful,
+	"00":  PayoutStatusSuccessful,
+
+	"201": PayoutStatusPending,
+	"202": PayoutStatusPending,
+	"01":  PayoutStatusPending,
+	"09":  PayoutStatusPending,
+	"25":  PayoutStatusPending,
+	"94":  PayoutStatusPending,
+	"97":  PayoutStatusPending,
+
+	"400": PayoutStatusFailed,
+	"404": PayoutStatusFailed,
+	"03":  PayoutStatusFailed,
+	"05":  PayoutStatusFailed,
+	"06":  PayoutStatusFailed,
+	"07":  PayoutStatusFailed,
+	"08":  PayoutStatusFailed,
+	"12":  PayoutStatusFailed,
+	"13":  PayoutStatusFailed,
+	"15":  PayoutStatusFailed,
+	"51":  PayoutStatusFailed,
+	"57":  PayoutStatusFailed,
+	"58":  PayoutStatusFailed,
+	"61":  PayoutStatusFailed,
+	"63":  PayoutStatusFailed,
+	"65":  PayoutStatusFailed,
+	"91":  PayoutStatusFailed,
+	"96":  PayoutStatusFailed,
+}
+
+type (
+	PayoutStatus string
+
+	//BankDetailSource is satisfied by WalletsAfrica.Payouts
+	BankDetailSource interface {
+		BankDetails(transactionReference string) (BankDetail, error)
+	}
+
+	//PayoutPollOptions controls the backoff between BankDetails lookups
+	PayoutPollOptions struct {
+		InitialInterval time.Duration
+		MaxInterval     time.Duration
+		Multiplier      float64
+		MaxAttempts     int //Defaults to DefaultPayoutPollMaxAttempts, so a wait ends even without a ctx deadline
+	}
+
+	//PayoutWaitError is returned when WaitForPayout stops before the transfer reached a terminal status.
+	//Err is the reason: ctx's error, a lookup error that retrying cannot fix, an unknown response code
+	//or running out of attempts. It unwraps to Err, so errors.Is(err, context.DeadlineExceeded) works.
+	PayoutWaitError struct {
+		Reference       string
+		Attempts        int
+		Err             error
+		LastLookupError error //The most recent failed lookup, if any
+	}
+)
+
+var apiErrorCodePattern = regexp.MustCompile(`Error Code: (\d+)`)
+
+//reversalPattern finds messages reporting a reversal, e.g. "Transaction Reversed", but not ones that only
+//mention the word, e.g. "irreversible", "not reversible" or "not reversed"
+var (
+	reversalPattern    = regexp.MustCompile(`(?i)\b(reversed|reversal)\b`)
+	notReversedPattern = regexp.MustCompile(`(?i)\bnot\s+(been\s+)?reversed\b`)
+)
+
+func (e *PayoutWaitError) Error() string {
+	message := fmt.Sprintf("waiting for payout %v stopped after %v lookups - %v", e.Reference, e.Attempts, e.Err)
+	if e.LastLookupError != nil && e.LastLookupError != e.Err {
+		message += fmt.Sprintf(" (last lookup error: %v)", e.LastLookupError)
+	}
+	return message
+}
+
+func (e *PayoutWaitError) Unwrap() error {
+	return e.Err
+}
+
+//Status interprets ResponseCode and Message. A message reporting a reversal wins over the code,
+//since reversed transfers keep the code of the original, successful, transfer.
+func (d BankDetail) Status() PayoutStatus {
+	if reversalPattern.MatchString(d.Message) && !notReversedPattern.MatchString(d.Message) {
+		return PayoutStatusReversed
+	}
+
+	if status, ok := PayoutStatusCodes[strings.TrimSpace(d.ResponseCode)]; ok {
+		return status
+	}
+	return PayoutStatusUnknown
+}
+
+//Terminal reports whether the status can no longer change
+func (s PayoutStatus) Terminal() bool {
+	return s == PayoutStatusSuccessful || s == PayoutStatusFailed || s == PayoutStatusReversed
+}
+
+//WaitForPayout polls BankDetails with exponential backoff until the transfer reaches a terminal
+//status, ctx ends or the attempts run out. Server and network errors are retried; see WaitForPayout.
+func (p *payouts) WaitForPayout(ctx context.Context, reference string) (BankDetail, PayoutStatus, error) {
+	return WaitForPayout(ctx, p, reference, PayoutPollOptions{})
+}
+
+//WaitForPayout is the polling loop behind Payouts.WaitForPayout for any BankDetailSource.
+//Server and network errors are retried. Client errors such as an unknown reference or bad keys, and
+//response codes missing from PayoutStatusCodes, stop the wait at once since retrying will not change them.
+//When it stops early it returns the last seen detail and status along with a *PayoutWaitError.
+func WaitForPayout(ctx context.Context, source BankDetailSource, reference string, options PayoutPollOptions) (BankDetail, PayoutStatus, error) {
+	if reference == "" {
+		return BankDetail{}, PayoutStatusPending, errors.New("transaction reference is required")
+	}
+
+	interval := options.InitialInterval
+	if interval <= 0 {
+		interval = DefaultPayoutPollInterval
+	}
+
+	maxInterval := options.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultPayoutPollMaxInterval
+	}
+
+	multiplier := options.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultPayoutPollMultiplier
+	}
+
+	maxAttempts := options.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultPayoutPollMaxAttempts
+	}
+
+	detail := BankDetail{}
+	status := PayoutStatusPending
+	waitErr := &PayoutWaitError{Reference: reference}
+	for {
+		waitErr.Attempts++
+		current, err := source.BankDetails(reference)
+		switch {
+		case err != nil && !retryablePayoutLookupError(err):
+			waitErr.Err, waitErr.LastLookupError = err, err
+			return detail, status, waitErr
+		case err != nil:
+			waitErr.LastLookupError = err
+		default:
+			detail, status = current, current.Status()
+			if status.Terminal() {
+				return detail, status, nil
+			}
+
+			if status == PayoutStatusUnknown {
+				waitErr.Err = errors.New(fmt.Sprintf("unrecognised response code %q", current.ResponseCode))
+				return detail, status, waitErr
+			}
+		}
+
+		if waitErr.Attempts >= maxAttempts {
+			waitErr.Err = errors.New("no terminal status within the maximum number of lookups")
+			return detail, status, waitErr
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			waitErr.Err = ctx.Err()
+			return detail, status, waitErr
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * multiplier)
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+//retryablePayoutLookupError reports whether a failed BankDetails lookup may succeed later.
+//API errors with a 4xx code other than 408 and 429 are permanent; anything else, e.g. a 5xx
+//or a network error, is worth retrying.
+func retryablePayoutLookupError(err error) bool {
+	if strings.Contains(strings.ToLower(err.Error()), "not found") {
+		return false
+	}
+
+	match := apiErrorCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return true
+	}
+
+	code, _ := strconv.Atoi(match[1])
+	if code >= 400 && code < 500 {
+		return code == 408 || code == 429
+	}
+	return true
+}
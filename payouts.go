@@ -103,3 +103,83 @@ func (p *payouts) BankDetails(transactionReference string) (BankDetail, error) {
 
 	return bankDetail, nil
 }
+
+//BankTransfer - sends money from the wallet to a bank account
+//Documentation: https://documenter.getpostman.com/view/10058163/SWLk4RPL?version=latest - the Payouts request for POST /transfer/bank/account
+func (p *payouts) BankTransfer(amount float64, bankCode, accountNumber, accountName, narration, transactionReference string) (BankTransferResult, error) {
+	result := BankTransferResult{}
+
+	if amount <= 0 {
+		return result, notSent(errors.New("amount must be greater than 0"))
+	}
+
+	if bankCode == "" || accountNumber == "" {
+		return result, notSent(errors.New("bank code and account number are required"))
+	}
+
+	//Catch mistyped account numbers before any money moves, when enabled in Config
+	if p.validateAccountNumbers {
+		if err := ValidateNUBAN(Bank{BankCode: bankCode}, accountNumber); err != nil {
+			return result, notSent(err)
+		}
+	}
+
+	if transactionReference == "" {
+		return result, notSent(errors.New("transaction reference is required"))
+	}
+
+	payloadValues := payloadBody{
+		"SecretKey":            p.secretKey,
+		"BankCode":             bankCode,
+		"AccountNumber":        accountNumber,
+		"AccountName":          accountName,
+		"TransactionReference": transactionReference,
+		"Amount":               amount,
+		"Narration":            narration,
+	}
+
+	payload, err := json.Marshal(payloadValues)
+	if err != nil {
+		return result, notSent(err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%v/transfer/bank/account", p.APIURL), bytes.NewReader(payload))
+	if err != nil {
+		return result, notSent(err)
+	}
+
+	resp, err := p.makeRequest(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	decodedResponseBody, err := p.unmarshallJson(resp.Body)
+	if err != nil {
+		return result, err
+	}
+
+	if _, ok := decodedResponseBody["Response"].(map[string]interface{}); ok {
+		result.ResponseCode = p.getResponseCode(decodedResponseBody)
+		result.Message = p.getResponseMessage(decodedResponseBody)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return result, errors.New(fmt.Sprintf("Request Failed - Error Code: %v | Message: %v", result.ResponseCode, result.Message))
+	}
+
+	result.TransactionReference = transactionReference
+	if data, ok := decodedResponseBody["Data"].(map[string]interface{}); ok {
+		if data["AmountCharged"] != nil {
+			result.AmountCharged = data["AmountCharged"].(float64)
+		}
+		if data["RecipientName"] != nil {
+			result.RecipientName = data["RecipientName"].(string)
+		}
+		if data["SessionId"] != nil {
+			result.SessionId = data["SessionId"].(string)
+		}
+	}
+
+	return result, nil
+}
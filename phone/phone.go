@@ -0,0 +1,206 @@
+//Package phone normalizes and validates Nigerian, Ghanaian and Kenyan mobile numbers and detects the network
+//they belong to. Networks use the codes returned by Airtime.GetProviders.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	CountryNigeria Country = "NG"
+	CountryGhana   Country = "GH"
+	CountryKenya   Country = "KE"
+
+	NetworkUnknown    Network = ""
+	NetworkMTN        Network = "mtn"
+	NetworkAirtel     Network = "airtel"
+	NetworkGlo        Network = "glo"
+	NetworkEtisalat   Network = "etisalat" //Now 9mobile, still listed under its old code by the API
+	NetworkVodafone   Network = "vodafone"
+	NetworkAirtelTigo Network = "airteltigo"
+	NetworkSafaricom  Network = "safaricom"
+	NetworkTelkom     Network = "telkom"
+)
+
+type (
+	Country string
+	Network string
+
+	//NumberingPlan describes the mobile numbers of a country. Prefixes are matched against the start of
+	//the national significant number (the number without the calling code or trunk 0), longest first.
+	NumberingPlan struct {
+		Country        Country
+		CallingCode    string
+		NationalLength int      //Digits in the national significant number
+		MobileRanges   []string //Leading digits every mobile number starts with
+		Prefixes       map[string]Network
+	}
+
+	//Number is a validated mobile number
+	Number struct {
+		Country        Country
+		CallingCode    string
+		NationalNumber string //National significant number, e.g. 8031234567
+	}
+)
+
+//Plans are the supported numbering plans. Operators get new prefixes from time to time; add them to
+//Prefixes here to have Network recognise them.
+var Plans = map[Country]NumberingPlan{
+	CountryNigeria: {
+		Country:        CountryNigeria,
+		CallingCode:    "234",
+		NationalLength: 10,
+		MobileRanges:   []string{"70", "80", "81", "90", "91"},
+		Prefixes: prefixes(map[Network][]string{
+			NetworkMTN:      {"703", "706", "803", "806", "810", "813", "814", "816", "903", "906", "913", "916", "7025", "7026", "704"},
+			NetworkAirtel:   {"701", "708", "802", "808", "812", "901", "902", "904", "907", "912"},
+			NetworkGlo:      {"705", "805", "807", "811", "815", "905", "915"},
+			NetworkEtisalat: {"809", "817", "818", "908", "909"},
+		}),
+	},
+	CountryGhana: {
+		Country:        CountryGhana,
+		CallingCode:    "233",
+		NationalLength: 9,
+		MobileRanges:   []string{"2", "5"},
+		Prefixes: prefixes(map[Network][]string{
+			NetworkMTN:        {"24", "25", "53", "54", "55", "59"},
+			NetworkVodafone:   {"20", "50"},
+			NetworkAirtelTigo: {"26", "27", "56", "57"},
+		}),
+	},
+	CountryKenya: {
+		Country:        CountryKenya,
+		CallingCode:    "254",
+		NationalLength: 9,
+		MobileRanges:   []string{"1", "7"},
+		Prefixes: prefixes(map[Network][]string{
+			NetworkSafaricom: {"70", "71", "72", "740", "741", "742", "743", "745", "746", "748", "757", "758", "759", "768", "769", "79", "110", "111"},
+			NetworkAirtel:    {"73", "750", "751", "752", "753", "754", "755", "756", "762", "78", "100", "101", "102"},
+			NetworkTelkom:    {"77"},
+		}),
+	},
+}
+
+//Parse reads a number written in national (0803...), international (234803..., +234803..., 00234803...)
+//or bare national significant (803...) form. Spaces, dashes, dots and brackets are ignored.
+//Numbers without a calling code are read with the numbering plan of defaultCountry.
+func Parse(raw string, defaultCountry Country) (Number, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == '+' || r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			return -1
+		}
+		return 'x'
+	}, strings.TrimSpace(raw))
+
+	if digits == "" || strings.Contains(digits, "x") {
+		return Number{}, errors.New(fmt.Sprintf("invalid phone number %q", raw))
+	}
+
+	international := strings.HasPrefix(strings.TrimSpace(raw), "+") || strings.HasPrefix(digits, "00")
+	digits = strings.TrimPrefix(digits, "00")
+
+	for _, plan := range Plans {
+		if strings.HasPrefix(digits, plan.CallingCode) && len(digits) == len(plan.CallingCode)+plan.NationalLength {
+			return plan.number(strings.TrimPrefix(digits, plan.CallingCode), raw)
+		}
+	}
+
+	if international {
+		return Number{}, errors.New(fmt.Sprintf("phone number %q is not in a supported country", raw))
+	}
+
+	plan, ok := Plans[defaultCountry]
+	if !ok {
+		return Number{}, errors.New(fmt.Sprintf("unsupported country %v", defaultCountry))
+	}
+
+	if len(digits) == plan.NationalLength+1 && strings.HasPrefix(digits, "0") {
+		digits = digits[1:]
+	}
+	return plan.number(digits, raw)
+}
+
+//Normalize parses raw as a Nigerian number unless it has a calling code and returns it in the international
+//format without "+" (e.g. 2348031234567), the format the API returns phone numbers in
+func Normalize(raw string) (string, error) {
+	number, err := Parse(raw, CountryNigeria)
+	if err != nil {
+		return "", err
+	}
+	return number.International(), nil
+}
+
+//DetectNetwork returns the network of raw, parsed as a Nigerian number unless it has a calling code
+func DetectNetwork(raw string) (Network, error) {
+	number, err := Parse(raw, CountryNigeria)
+	if err != nil {
+		return NetworkUnknown, err
+	}
+
+	network := number.Network()
+	if network == NetworkUnknown {
+		return network, errors.New(fmt.Sprintf("unknown network for phone number %v", number.E164()))
+	}
+	return network, nil
+}
+
+//International formats the number with its calling code and no "+", e.g. 2348031234567
+func (n Number) International() string {
+	return n.CallingCode + n.NationalNumber
+}
+
+//E164 formats the number as +2348031234567
+func (n Number) E164() string {
+	return "+" + n.International()
+}
+
+//National formats the number with the trunk 0, e.g. 08031234567
+func (n Number) National() string {
+	return "0" + n.NationalNumber
+}
+
+func (n Number) String() string {
+	return n.E164()
+}
+
+//Network returns the operator the number's prefix was allocated to, or NetworkUnknown.
+//Numbers ported to another operator keep their original prefix, so treat this as a best guess.
+func (n Number) Network() Network {
+	plan := Plans[n.Country]
+	for length := len(n.NationalNumber); length > 0; length-- {
+		if network, ok := plan.Prefixes[n.NationalNumber[:length]]; ok {
+			return network
+		}
+	}
+	return NetworkUnknown
+}
+
+func (p NumberingPlan) number(national, raw string) (Number, error) {
+	if len(national) != p.NationalLength {
+		return Number{}, errors.New(fmt.Sprintf("phone number %q does not have %v digits after the country code", raw, p.NationalLength))
+	}
+
+	for _, mobileRange := range p.MobileRanges {
+		if strings.HasPrefix(national, mobileRange) {
+			return Number{Country: p.Country, CallingCode: p.CallingCode, NationalNumber: national}, nil
+		}
+	}
+	return Number{}, errors.New(fmt.Sprintf("phone number %q is not a %v mobile number", raw, p.Country))
+}
+
+func prefixes(networks map[Network][]string) map[string]Network {
+	byPrefix := map[string]Network{}
+	for network, list := range networks {
+		for _, prefix := range list {
+			byPrefix[prefix] = network
+		}
+	}
+	return byPrefix
+}
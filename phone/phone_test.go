@@ -0,0 +1,56 @@
+package phone
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, raw := range []string{"08031234567", "+2348031234567", "2348031234567", "002348031234567", "0803 123 4567", "(+234) 803-123-4567", "8031234567"} {
+		normalized, err := Normalize(raw)
+		assert.Nil(t, err, raw)
+		assert.Equal(t, "2348031234567", normalized, raw)
+	}
+
+	for _, raw := range []string{"", "0803123456", "080312345678", "0803123456a", "06031234567", "+4407911123456"} {
+		_, err := Normalize(raw)
+		assert.NotNil(t, err, raw)
+	}
+}
+
+func TestParse(t *testing.T) {
+	number, err := Parse("024 123 4567", CountryGhana)
+	assert.Nil(t, err)
+	assert.Equal(t, "+233241234567", number.E164())
+	assert.Equal(t, "0241234567", number.National())
+	assert.Equal(t, NetworkMTN, number.Network())
+
+	//A calling code wins over the default country
+	number, err = Parse("+254 712 345678", CountryNigeria)
+	assert.Nil(t, err)
+	assert.Equal(t, CountryKenya, number.Country)
+	assert.Equal(t, NetworkSafaricom, number.Network())
+
+	_, err = Parse("0712345678", "ZA")
+	assert.NotNil(t, err)
+}
+
+func TestDetectNetwork(t *testing.T) {
+	networks := map[string]Network{
+		"08031234567":    NetworkMTN,
+		"07025123456":    NetworkMTN,
+		"07021234567":    NetworkUnknown,
+		"+2348021234567": NetworkAirtel,
+		"08051234567":    NetworkGlo,
+		"09091234567":    NetworkEtisalat,
+		"+254731234567":  NetworkAirtel,
+		"+254771234567":  NetworkTelkom,
+		"+233201234567":  NetworkVodafone,
+	}
+
+	for raw, expected := range networks {
+		network, err := DetectNetwork(raw)
+		assert.Equal(t, expected, network, raw)
+		assert.Equal(t, expected == NetworkUnknown, err != nil, raw)
+	}
+}
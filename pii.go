@@ -0,0 +1,285 @@
+package gowalletsafrica
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	PIIModeFull   PIIMode = "full"
+	PIIModeMasked PIIMode = "masked"
+
+	piiRedacted        string = "[redacted]"
+	piiEncryptedPrefix string = "enc:v1:"
+)
+
+type (
+	PIIMode string
+
+	//PIIEncryptor encrypts individual fields with AES-GCM so identities can be stored at rest.
+	//Each value gets a random nonce and is bound to its field name, so values cannot be swapped between fields.
+	PIIEncryptor struct {
+		aead cipher.AEAD
+	}
+
+	resolveBVNFields ResolveBVN
+	walletFields     Wallet
+)
+
+//MaskBVN keeps the last 4 digits, e.g. *******5915
+func MaskBVN(bvn string) string {
+	return maskKeepLast(bvn, 4)
+}
+
+//MaskPhoneNumber keeps the last 3 digits, e.g. ********415
+func MaskPhoneNumber(phoneNumber string) string {
+	return maskKeepLast(phoneNumber, 3)
+}
+
+//MaskAccountNumber keeps the last 4 digits, e.g. ******7003
+func MaskAccountNumber(accountNumber string) string {
+	return maskKeepLast(accountNumber, 4)
+}
+
+//MaskEmail keeps the first letter and the domain, e.g. t***@example.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return maskKeepLast(email, 0)
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+//MaskName keeps the first letter of every word, e.g. J*** D**
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		runes := []rune(word)
+		words[i] = string(runes[:1]) + strings.Repeat("*", len(runes)-1)
+	}
+	return strings.Join(words, " ")
+}
+
+//Redact returns a copy with every personal field masked and the picture removed, safe to log
+func (r ResolveBVN) Redact() ResolveBVN {
+	r.FirstName = MaskName(r.FirstName)
+	r.LastName = MaskName(r.LastName)
+	r.MiddleName = MaskName(r.MiddleName)
+	r.NameOnCard = MaskName(r.NameOnCard)
+	r.Email = MaskEmail(r.Email)
+	r.PhoneNumber = MaskPhoneNumber(r.PhoneNumber)
+	r.BVN = MaskBVN(r.BVN)
+	r.DateOfBirth = redact(r.DateOfBirth)
+	r.Picture = redact(r.Picture)
+	return r
+}
+
+//String describes the result with personal fields masked
+func (r ResolveBVN) String() string {
+	redacted := r.Redact()
+	return fmt.Sprintf("ResolveBVN{BVN: %v, FirstName: %v, MiddleName: %v, LastName: %v, DateOfBirth: %v, PhoneNumber: %v, Email: %v, LevelOfAccount: %v, WatchListed: %v}",
+		redacted.BVN, redacted.FirstName, redacted.MiddleName, redacted.LastName, redacted.DateOfBirth, redacted.PhoneNumber, redacted.Email, r.LevelOfAccount, r.WatchListed)
+}
+
+//Format prints String for %v (including %+v and %#v), %s and %q so personal fields never reach logs by accident
+func (r ResolveBVN) Format(f fmt.State, verb rune) {
+	formatMasked(f, verb, r.String(), r)
+}
+
+//MarshalJSON writes every field as is so stored JSON keeps working. Marshal Redact() or use
+//MarshalJSONMode to write masked JSON.
+func (r ResolveBVN) MarshalJSON() ([]byte, error) {
+	return r.MarshalJSONMode(PIIModeFull)
+}
+
+//MarshalJSONMode marshals the result with every personal field as is or masked
+func (r ResolveBVN) MarshalJSONMode(mode PIIMode) ([]byte, error) {
+	if mode == PIIModeMasked {
+		r = r.Redact()
+	}
+	return json.Marshal(resolveBVNFields(r))
+}
+
+//Redact returns a copy with every personal field masked and the password removed, safe to log
+func (w Wallet) Redact() Wallet {
+	w.FirstName = MaskName(w.FirstName)
+	w.LastName = MaskName(w.LastName)
+	w.AccountName = MaskName(w.AccountName)
+	w.Username = MaskName(w.Username)
+	w.Email = MaskEmail(w.Email)
+	w.PhoneNumber = MaskPhoneNumber(w.PhoneNumber)
+	w.BVN = MaskBVN(w.BVN)
+	w.DateOfBirth = redact(w.DateOfBirth)
+	w.AccountNumber = MaskAccountNumber(w.AccountNumber)
+	w.AccountNo = MaskAccountNumber(w.AccountNo)
+	w.Password = redact(w.Password)
+	return w
+}
+
+//String describes the wallet with personal fields masked
+func (w Wallet) String() string {
+	redacted := w.Redact()
+	return fmt.Sprintf("Wallet{AccountNo: %v, AccountName: %v, Bank: %v, FirstName: %v, LastName: %v, Email: %v, PhoneNumber: %v, BVN: %v, DateOfBirth: %v, Password: %v}",
+		redacted.AccountNo, redacted.AccountName, w.Bank, redacted.FirstName, redacted.LastName, redacted.Email, redacted.PhoneNumber, redacted.BVN, redacted.DateOfBirth, redacted.Password)
+}
+
+//Format prints String for %v (including %+v and %#v), %s and %q so personal fields never reach logs by accident
+func (w Wallet) Format(f fmt.State, verb rune) {
+	formatMasked(f, verb, w.String(), w)
+}
+
+//MarshalJSON writes every field as is so stored JSON keeps working. Marshal Redact() or use
+//MarshalJSONMode to write masked JSON.
+func (w Wallet) MarshalJSON() ([]byte, error) {
+	return w.MarshalJSONMode(PIIModeFull)
+}
+
+//MarshalJSONMode marshals the wallet with every personal field as is or masked
+func (w Wallet) MarshalJSONMode(mode PIIMode) ([]byte, error) {
+	if mode == PIIModeMasked {
+		w = w.Redact()
+	}
+	return json.Marshal(walletFields(w))
+}
+
+//NewPIIEncryptor creates an encryptor with a 16, 24 or 32 byte AES key. Keep the key out of the
+//store the encrypted values are written to.
+func NewPIIEncryptor(key []byte) (*PIIEncryptor, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &PIIEncryptor{aead: aead}, nil
+}
+
+//EncryptField encrypts value for field. Empty values stay empty.
+func (e *PIIEncryptor) EncryptField(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := e.aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return piiEncryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+//DecryptField reverses EncryptField. It fails if value was encrypted for another field or with another key.
+func (e *PIIEncryptor) DecryptField(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	if !strings.HasPrefix(value, piiEncryptedPrefix) {
+		return "", errors.New(fmt.Sprintf("%v is not an encrypted value", field))
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, piiEncryptedPrefix))
+	if err != nil || len(sealed) < e.aead.NonceSize() {
+		return "", errors.New(fmt.Sprintf("%v is not an encrypted value", field))
+	}
+
+	nonce, ciphertext := sealed[:e.aead.NonceSize()], sealed[e.aead.NonceSize():]
+	plaintext, err := e.aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%v could not be decrypted - %v", field, err))
+	}
+	return string(plaintext), nil
+}
+
+//EncryptResolveBVN returns a copy of r with every personal field encrypted
+func (e *PIIEncryptor) EncryptResolveBVN(r ResolveBVN) (ResolveBVN, error) {
+	if err := e.applyResolveBVN(&r, e.EncryptField); err != nil {
+		return ResolveBVN{}, err
+	}
+	return r, nil
+}
+
+//DecryptResolveBVN reverses EncryptResolveBVN
+func (e *PIIEncryptor) DecryptResolveBVN(r ResolveBVN) (ResolveBVN, error) {
+	if err := e.applyResolveBVN(&r, e.DecryptField); err != nil {
+		return ResolveBVN{}, err
+	}
+	return r, nil
+}
+
+func (e *PIIEncryptor) applyResolveBVN(r *ResolveBVN, transform func(field, value string) (string, error)) error {
+	fields := map[string]*string{
+		"FirstName":   &r.FirstName,
+		"LastName":    &r.LastName,
+		"MiddleName":  &r.MiddleName,
+		"NameOnCard":  &r.NameOnCard,
+		"Email":       &r.Email,
+		"PhoneNumber": &r.PhoneNumber,
+		"BVN":         &r.BVN,
+		"DateOfBirth": &r.DateOfBirth,
+		"Picture":     &r.Picture,
+	}
+
+	for field, value := range fields {
+		transformed, err := transform(field, *value)
+		if err != nil {
+			return err
+		}
+		*value = transformed
+	}
+	return nil
+}
+
+//formatMasked prints masked, the masked description of value, honouring the width, precision and - flag of
+//%v, %s and %q. Other verbs print a bad verb error like fmt does, e.g. %!d(gowalletsafrica.Wallet).
+func formatMasked(f fmt.State, verb rune, masked string, value interface{}) {
+	switch verb {
+	case 'v', 's', 'q':
+	default:
+		fmt.Fprintf(f, "%%!%c(%T)", verb, value)
+		return
+	}
+
+	directive := "%"
+	if f.Flag('-') {
+		directive += "-"
+	}
+	if width, ok := f.Width(); ok {
+		directive += strconv.Itoa(width)
+	}
+	if precision, ok := f.Precision(); ok {
+		directive += "." + strconv.Itoa(precision)
+	}
+	if verb == 'q' {
+		fmt.Fprintf(f, directive+"q", masked)
+		return
+	}
+	fmt.Fprintf(f, directive+"s", masked)
+}
+
+func maskKeepLast(value string, keep int) string {
+	runes := []rune(value)
+	if len(runes) <= keep {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return piiRedacted
+}
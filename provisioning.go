@@ -0,0 +1,279 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	//WalletRequest is one sub wallet to create. DateOfBirth is optional and uses DateFormat.
+	WalletRequest struct {
+		FirstName   string   `json:"first_name"`
+		LastName    string   `json:"last_name"`
+		Email       string   `json:"email"`
+		DateOfBirth string   `json:"date_of_birth"`
+		Currency    Currency `json:"currency"`
+	}
+
+	//ProvisionResult is the outcome of one WalletRequest. Password is never written by the JSON or CSV
+	//reports; use WritePasswords or WritePasswordFile to store it separately.
+	ProvisionResult struct {
+		Request     WalletRequest   `json:"request"`
+		Status      BatchItemStatus `json:"status"`
+		Error       string          `json:"error,omitempty"`
+		AccountNo   string          `json:"account_no,omitempty"`
+		AccountName string          `json:"account_name,omitempty"`
+		Bank        string          `json:"bank,omitempty"`
+		PhoneNumber string          `json:"phone_number,omitempty"`
+		Password    string          `json:"-"`
+		CompletedAt time.Time       `json:"completed_at"`
+	}
+
+	//WalletGenerator is satisfied by WalletsAfrica.Wallets
+	WalletGenerator interface {
+		Generate(currency Currency, firstName, lastName, email, dateOfBirth string) (Wallet, error)
+	}
+
+	//WalletLister is satisfied by WalletsAfrica.Self
+	WalletLister interface {
+		GetWallets() (Wallets, error)
+	}
+
+	//Provisioner creates sub wallets in bulk with bounded concurrency and an optional rate limit.
+	//Requests whose email already belongs to a wallet in Existing are skipped.
+	Provisioner struct {
+		Wallets       WalletGenerator
+		Existing      WalletLister
+		Concurrency   int     //Defaults to DefaultBatchConcurrency
+		RatePerSecond float64 //Zero means unlimited
+	}
+
+	ProvisionSummary struct {
+		Total   int               `json:"total"`
+		Created int               `json:"created"`
+		Skipped int               `json:"skipped"`
+		Failed  int               `json:"failed"`
+		Invalid int               `json:"invalid"`
+		Results []ProvisionResult `json:"results"` //In the order of the requests
+	}
+)
+
+//ReadWalletRequestsCSV reads requests from a CSV file with a header row containing first_name, last_name,
+//email and optionally date_of_birth and currency. Currency defaults to NGN.
+func ReadWalletRequestsCSV(r io.Reader) ([]WalletRequest, error) {
+	rows, err := readCSVRows(r, "first_name", "last_name", "email")
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []WalletRequest{}
+	for _, row := range rows {
+		currency := Currency(strings.ToUpper(row["currency"]))
+		if currency == "" {
+			currency = CurrencyNigeria
+		}
+
+		requests = append(requests, WalletRequest{
+			FirstName:   row["first_name"],
+			LastName:    row["last_name"],
+			Email:       row["email"],
+			DateOfBirth: row["date_of_birth"],
+			Currency:    currency,
+		})
+	}
+	return requests, nil
+}
+
+//Validate returns a result for every request, BatchItemValidated for the ones that may be sent and
+//BatchItemInvalid with the reason for the others. Emails must be unique within the batch.
+func (p *Provisioner) Validate(requests []WalletRequest) []ProvisionResult {
+	emails := map[string]int{}
+	for _, request := range requests {
+		emails[normalizeEmail(request.Email)]++
+	}
+
+	results := make([]ProvisionResult, len(requests))
+	for i, request := range requests {
+		problem := ""
+		switch {
+		case strings.TrimSpace(request.FirstName) == "" || strings.TrimSpace(request.LastName) == "":
+			problem = "first name and last name are required"
+		case !isEmail(request.Email):
+			problem = fmt.Sprintf("invalid email %v", request.Email)
+		case emails[normalizeEmail(request.Email)] > 1:
+			problem = "email is used more than once in the batch"
+		case !isSupportedCurrency(request.Currency):
+			problem = fmt.Sprintf("unsupported currency %v", request.Currency)
+		case request.DateOfBirth != "":
+			if _, err := time.Parse(DateFormat, request.DateOfBirth); err != nil {
+				problem = fmt.Sprintf("date of birth %v is not in the %v format", request.DateOfBirth, DateFormat)
+			}
+		}
+
+		results[i] = ProvisionResult{Request: request, Status: BatchItemValidated}
+		if problem != "" {
+			results[i].Status, results[i].Error = BatchItemInvalid, problem
+		}
+	}
+	return results
+}
+
+//Run validates every request, skips the ones whose email already has a wallet and creates the rest.
+//It stops starting new requests when ctx ends and returns the summary so far with ctx's error;
+//requests that were not attempted keep the BatchItemValidated status.
+func (p *Provisioner) Run(ctx context.Context, requests []WalletRequest) (ProvisionSummary, error) {
+	results := p.Validate(requests)
+	summary := ProvisionSummary{Total: len(requests), Results: results}
+	if p.Wallets == nil {
+		return summary, errors.New("provisioner - wallets service is required")
+	}
+
+	existing := map[string]bool{}
+	if p.Existing != nil {
+		wallets, err := p.Existing.GetWallets()
+		if err != nil {
+			return summary, err
+		}
+
+		for _, wallet := range wallets {
+			existing[normalizeEmail(wallet.Email)] = true
+		}
+	}
+
+	pending := []int{}
+	for i, result := range results {
+		if result.Status != BatchItemValidated {
+			continue
+		}
+
+		if existing[normalizeEmail(result.Request.Email)] {
+			results[i].Status, results[i].Error = BatchItemSkipped, "a wallet with this email already exists"
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	concurrency := p.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	mu := sync.Mutex{}
+	runErr := runConcurrently(ctx, len(pending), concurrency, newRateLimiter(p.RatePerSecond), func(i int) {
+		result := p.create(results[pending[i]].Request)
+
+		mu.Lock()
+		results[pending[i]] = result
+		mu.Unlock()
+	})
+
+	for _, result := range results {
+		switch result.Status {
+		case BatchItemSucceeded:
+			summary.Created++
+		case BatchItemSkipped:
+			summary.Skipped++
+		case BatchItemFailed:
+			summary.Failed++
+		case BatchItemInvalid:
+			summary.Invalid++
+		}
+	}
+	return summary, runErr
+}
+
+func (p *Provisioner) create(request WalletRequest) ProvisionResult {
+	result := ProvisionResult{Request: request}
+	wallet, err := p.Wallets.Generate(request.Currency, request.FirstName, request.LastName, request.Email, request.DateOfBirth)
+	result.CompletedAt = time.Now()
+
+	if err != nil {
+		result.Status = BatchItemFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = BatchItemSucceeded
+	result.AccountNo = wallet.AccountNo
+	result.AccountName = wallet.AccountName
+	result.Bank = wallet.Bank
+	result.PhoneNumber = wallet.PhoneNumber
+	result.Password = wallet.Password
+	return result
+}
+
+//WriteJSON writes the summary, including every result but no passwords, as a JSON document
+func (s ProvisionSummary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+//WriteCSV writes one row per result with the account details of the created wallets but no passwords
+func (s ProvisionSummary) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"email", "first_name", "last_name", "currency", "status", "error", "account_no", "account_name", "bank", "phone_number"})
+	for _, r := range s.Results {
+		writer.Write([]string{
+			r.Request.Email, r.Request.FirstName, r.Request.LastName, string(r.Request.Currency),
+			string(r.Status), r.Error, r.AccountNo, r.AccountName, r.Bank, r.PhoneNumber,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//WritePasswords writes the email, account number and password of every created wallet as CSV
+func (s ProvisionSummary) WritePasswords(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"email", "account_no", "password"})
+	for _, r := range s.Results {
+		if r.Status == BatchItemSucceeded {
+			writer.Write([]string{r.Request.Email, r.AccountNo, r.Password})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//WritePasswordFile writes WritePasswords to path, readable and writable by the owner only.
+//It refuses to overwrite an existing file.
+func (s ProvisionSummary) WritePasswordFile(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := s.WritePasswords(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func isEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == strings.TrimSpace(email)
+}
+
+func isSupportedCurrency(currency Currency) bool {
+	for _, supported := range SupportedCurrencies {
+		if currency == supported {
+			return true
+		}
+	}
+	return false
+}
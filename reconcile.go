@@ -0,0 +1,322 @@
+package gowalletsafrica
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RecordSourceTransaction string = "transaction"
+	RecordSourceBankDetail  string = "bank_detail"
+
+	MatchedByReference  string = "reference"
+	MatchedByAmountDate string = "amount_date"
+
+	BucketMatched           string = "matched"
+	BucketMissingOnProvider string = "missing_on_provider"
+	BucketMissingInternally string = "missing_internally"
+	BucketAmountMismatch    string = "amount_mismatch"
+
+	DefaultReconcileDateTolerance time.Duration = 24 * time.Hour
+)
+
+type (
+	//LedgerEntry is one credit or payout recorded in our own ledger.
+	//Amount is always positive, Type is TransactionTypeNameCredit or TransactionTypeNameDebit.
+	LedgerEntry struct {
+		Reference string    `json:"reference"`
+		Amount    float64   `json:"amount"`
+		Currency  Currency  `json:"currency"`
+		Type      string    `json:"type"`
+		Date      time.Time `json:"date"`
+	}
+
+	//Ledger is implemented by the internal ledger being reconciled
+	Ledger interface {
+		LedgerEntries(currency Currency, from, to time.Time) ([]LedgerEntry, error)
+	}
+
+	//ProviderRecord is a Transaction or BankDetail normalised for reconciliation.
+	//Reference is empty when the provider data does not carry one.
+	ProviderRecord struct {
+		Reference string    `json:"reference"`
+		Amount    float64   `json:"amount"`
+		Currency  Currency  `json:"currency"`
+		Type      string    `json:"type"`
+		Date      time.Time `json:"date"`
+		Source    string    `json:"source"`
+	}
+
+	ReconciliationMatch struct {
+		Entry      LedgerEntry    `json:"entry"`
+		Record     ProviderRecord `json:"record"`
+		MatchedBy  string         `json:"matched_by"`
+		Difference float64        `json:"difference"` //Provider amount minus ledger amount
+	}
+
+	ReconciliationReport struct {
+		Matched           []ReconciliationMatch `json:"matched"`
+		AmountMismatch    []ReconciliationMatch `json:"amount_mismatch"`
+		MissingOnProvider []LedgerEntry         `json:"missing_on_provider"`
+		MissingInternally []ProviderRecord      `json:"missing_internally"`
+	}
+
+	ReconcileOptions struct {
+		//DateTolerance is how far apart entries matched on amount alone may be.
+		//Defaults to DefaultReconcileDateTolerance.
+		DateTolerance time.Duration
+	}
+
+	//Reconciler reconciles a Ledger against provider data for a period
+	Reconciler struct {
+		Ledger  Ledger
+		Options ReconcileOptions
+	}
+)
+
+//Reconcile fetches ledger entries for the period and reconciles them against records. Entries and records
+//outside the currency or the period, from and to included, are left out on both sides, so neither shows up
+//as missing on the other.
+func (r Reconciler) Reconcile(currency Currency, from, to time.Time, records []ProviderRecord) (ReconciliationReport, error) {
+	if r.Ledger == nil {
+		return ReconciliationReport{}, errors.New("reconciler - ledger is required")
+	}
+
+	entries, err := r.Ledger.LedgerEntries(currency, from, to)
+	if err != nil {
+		return ReconciliationReport{}, err
+	}
+
+	inPeriod := func(entryCurrency Currency, date time.Time) bool {
+		return strings.EqualFold(string(entryCurrency), string(currency)) && !date.Before(from) && !date.After(to)
+	}
+
+	includedEntries := []LedgerEntry{}
+	for _, entry := range entries {
+		if inPeriod(entry.Currency, entry.Date) {
+			includedEntries = append(includedEntries, entry)
+		}
+	}
+
+	includedRecords := []ProviderRecord{}
+	for _, record := range records {
+		if inPeriod(record.Currency, record.Date) {
+			includedRecords = append(includedRecords, record)
+		}
+	}
+	return Reconcile(includedEntries, includedRecords, r.Options), nil
+}
+
+//Reconcile matches ledger entries with provider records. Entries are first matched on reference
+//(an amount difference puts the pair in AmountMismatch), then remaining entries are matched on
+//currency, type and amount to the closest record within the date tolerance.
+func Reconcile(entries []LedgerEntry, records []ProviderRecord, options ReconcileOptions) ReconciliationReport {
+	tolerance := options.DateTolerance
+	if tolerance <= 0 {
+		tolerance = DefaultReconcileDateTolerance
+	}
+
+	report := ReconciliationReport{
+		Matched:           []ReconciliationMatch{},
+		AmountMismatch:    []ReconciliationMatch{},
+		MissingOnProvider: []LedgerEntry{},
+		MissingInternally: []ProviderRecord{},
+	}
+
+	used := make([]bool, len(records))
+	byReference := map[string]int{}
+	for i, record := range records {
+		if record.Reference == "" {
+			continue
+		}
+		if _, ok := byReference[record.Reference]; !ok {
+			byReference[record.Reference] = i
+		}
+	}
+
+	unmatched := []LedgerEntry{}
+	for _, entry := range entries {
+		i, ok := byReference[entry.Reference]
+		if entry.Reference == "" || !ok || used[i] {
+			unmatched = append(unmatched, entry)
+			continue
+		}
+
+		used[i] = true
+		match := ReconciliationMatch{Entry: entry, Record: records[i], MatchedBy: MatchedByReference, Difference: records[i].Amount - entry.Amount}
+		if amountsEqual(entry.Amount, records[i].Amount, entry.Currency) {
+			match.Difference = 0
+			report.Matched = append(report.Matched, match)
+		} else {
+			report.AmountMismatch = append(report.AmountMismatch, match)
+		}
+	}
+
+	for _, entry := range unmatched {
+		best := -1
+		var bestDistance time.Duration
+		for i, record := range records {
+			if used[i] || !strings.EqualFold(string(record.Currency), string(entry.Currency)) ||
+				!strings.EqualFold(record.Type, entry.Type) || !amountsEqual(record.Amount, entry.Amount, entry.Currency) {
+				continue
+			}
+
+			//Records carrying a different reference belong to another entry
+			if record.Reference != "" && entry.Reference != "" && record.Reference != entry.Reference {
+				continue
+			}
+
+			distance := absDuration(record.Date.Sub(entry.Date))
+			if distance <= tolerance && (best < 0 || distance < bestDistance) {
+				best, bestDistance = i, distance
+			}
+		}
+
+		if best < 0 {
+			report.MissingOnProvider = append(report.MissingOnProvider, entry)
+			continue
+		}
+
+		used[best] = true
+		report.Matched = append(report.Matched, ReconciliationMatch{Entry: entry, Record: records[best], MatchedBy: MatchedByAmountDate})
+	}
+
+	for i, record := range records {
+		if !used[i] {
+			report.MissingInternally = append(report.MissingInternally, record)
+		}
+	}
+	return report
+}
+
+//ProviderRecordsFromTransactions converts transactions into provider records.
+//extractReference may be nil, in which case records carry no reference.
+func ProviderRecordsFromTransactions(transactions Transactions, extractReference func(Transaction) string) ([]ProviderRecord, error) {
+	records := []ProviderRecord{}
+	for _, t := range transactions {
+		date, err := t.Time()
+		if err != nil {
+			return records, err
+		}
+
+		if _, err := t.SignedAmount(); err != nil {
+			return records, err
+		}
+
+		record := ProviderRecord{
+			Amount:   math.Abs(t.Amount),
+			Currency: Currency(strings.ToUpper(t.Currency)),
+			Type:     t.Type,
+			Date:     date,
+			Source:   RecordSourceTransaction,
+		}
+		if extractReference != nil {
+			record.Reference = extractReference(t)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+//ProviderRecordsFromBankDetails converts bank transfer details, keyed by the reference they were
+//looked up with, into debit provider records. Bank transfers are always in Naira.
+func ProviderRecordsFromBankDetails(details map[string]BankDetail) ([]ProviderRecord, error) {
+	references := make([]string, 0, len(details))
+	for reference := range details {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+
+	records := []ProviderRecord{}
+	for _, reference := range references {
+		detail := details[reference]
+		date, err := ParseTransactionDate(detail.DateTransferred)
+		if err != nil {
+			return records, err
+		}
+
+		records = append(records, ProviderRecord{
+			Reference: reference,
+			Amount:    math.Abs(detail.Amount),
+			Currency:  CurrencyNigeria,
+			Type:      TransactionTypeNameDebit,
+			Date:      date,
+			Source:    RecordSourceBankDetail,
+		})
+	}
+	return records, nil
+}
+
+//ReferenceFromNarration returns the first word of the narration that was produced by a
+//ReferenceGenerator, for use with ProviderRecordsFromTransactions
+func ReferenceFromNarration(t Transaction) string {
+	for _, word := range strings.FieldsFunc(t.Narration, func(r rune) bool { return !isReferenceChar(r) }) {
+		if _, err := ParseReference(word); err == nil {
+			return word
+		}
+	}
+	return ""
+}
+
+//OK reports whether every entry and record was matched without differences
+func (r ReconciliationReport) OK() bool {
+	return len(r.AmountMismatch) == 0 && len(r.MissingOnProvider) == 0 && len(r.MissingInternally) == 0
+}
+
+//WriteJSON writes the report as a JSON document
+func (r ReconciliationReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+//WriteCSV writes the report with one row per entry or record, labelled with its bucket
+func (r ReconciliationReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"bucket", "reference", "currency", "type", "ledger_amount", "provider_amount", "difference", "ledger_date", "provider_date", "matched_by", "source"})
+
+	writeMatch := func(bucket string, m ReconciliationMatch) {
+		reference := m.Entry.Reference
+		if reference == "" {
+			reference = m.Record.Reference
+		}
+		writer.Write([]string{
+			bucket, reference, string(m.Entry.Currency), m.Entry.Type,
+			FormatAmount(m.Entry.Amount, m.Entry.Currency), FormatAmount(m.Record.Amount, m.Entry.Currency),
+			FormatAmount(m.Difference, m.Entry.Currency),
+			m.Entry.Date.Format(time.RFC3339), m.Record.Date.Format(time.RFC3339), m.MatchedBy, m.Record.Source,
+		})
+	}
+
+	for _, m := range r.Matched {
+		writeMatch(BucketMatched, m)
+	}
+
+	for _, m := range r.AmountMismatch {
+		writeMatch(BucketAmountMismatch, m)
+	}
+
+	for _, e := range r.MissingOnProvider {
+		writer.Write([]string{BucketMissingOnProvider, e.Reference, string(e.Currency), e.Type, FormatAmount(e.Amount, e.Currency), "", "", e.Date.Format(time.RFC3339), "", "", ""})
+	}
+
+	for _, p := range r.MissingInternally {
+		writer.Write([]string{BucketMissingInternally, p.Reference, string(p.Currency), p.Type, "", FormatAmount(p.Amount, p.Currency), "", "", p.Date.Format(time.RFC3339), "", p.Source})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,362 @@
+package gowalletsafrica
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ReferenceMaxLength        int = 64
+	ReferenceMaxTenantLen     int = 12
+	ReferenceMaxPurposeLen    int = 8
+	referenceSeparator            = "-"
+	referenceEnvSandbox           = 'S'
+	referenceEnvLive              = 'L'
+	referenceKindULID             = 'U'
+	referenceKindSequence         = 'Q'
+	referenceKindUUID             = 'R'
+	crockfordAlphabet             = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	ulidLength                    = 26
+	uuidHexLength                 = 32
+	ReferenceStrategyULID         = "ulid"
+	ReferenceStrategySequence     = "sequence"
+	ReferenceStrategyUUID         = "uuid"
+)
+
+type (
+	//ReferenceStrategy produces the unique body of a transaction reference.
+	//Marker is the single letter or digit recorded in the reference to identify the strategy;
+	//U, Q and R are used by the built-in strategies.
+	ReferenceStrategy interface {
+		Name() string
+		Marker() byte
+		Next() (string, error)
+	}
+
+	//ReferenceGenerator builds transaction references that embed the environment, tenant and purpose
+	//alongside a unique body produced by the configured strategy.
+	//Generated references look like `SU-ACME-PAYOUT-01ARZ3NDEKTSV4RRFFQ69G5FAV`.
+	ReferenceGenerator struct {
+		Strategy    ReferenceStrategy
+		Environment string
+		Tenant      string
+	}
+
+	//ReferenceMetadata is the information recovered from a reference by ParseReference
+	ReferenceMetadata struct {
+		Environment string
+		Strategy    string //The strategy name, or the marker for strategies other than the built-in ones
+		Tenant      string
+		Purpose     string
+		Body        string
+		Time        time.Time //Only set for ULID references
+	}
+
+	ulidStrategy struct {
+		mu       sync.Mutex
+		lastTime uint64
+		lastRand [10]byte
+	}
+
+	sequenceStrategy struct {
+		mu     sync.Mutex
+		prefix string
+		next   uint64
+		width  int
+	}
+
+	uuidStrategy struct{}
+)
+
+//NewReferenceGenerator creates a generator for the provided environment and tenant.
+//Returns an error if the environment is unknown or the tenant does not fit the reference limits
+func NewReferenceGenerator(environment, tenant string, strategy ReferenceStrategy) (*ReferenceGenerator, error) {
+	if environment != EnvSandbox && environment != EnvLive {
+		return nil, errors.New(fmt.Sprintf("reference generator - provided enviroment is not supported. - Only %v or %v is allowed", EnvLive, EnvSandbox))
+	}
+
+	if strategy == nil {
+		return nil, errors.New("reference generator - strategy is required")
+	}
+
+	if err := validateReferenceSegment("tenant", tenant, ReferenceMaxTenantLen); err != nil {
+		return nil, err
+	}
+
+	return &ReferenceGenerator{Strategy: strategy, Environment: environment, Tenant: strings.ToUpper(tenant)}, nil
+}
+
+//Generate returns a new reference for the provided purpose (e.g. PAYOUT, CREDIT)
+func (g *ReferenceGenerator) Generate(purpose string) (string, error) {
+	if err := validateReferenceSegment("purpose", purpose, ReferenceMaxPurposeLen); err != nil {
+		return "", err
+	}
+
+	body, err := g.Strategy.Next()
+	if err != nil {
+		return "", err
+	}
+
+	kind := g.Strategy.Marker()
+	if !isAlphanumeric(rune(kind)) {
+		return "", errors.New(fmt.Sprintf("reference generator - strategy %v has invalid marker %q", g.Strategy.Name(), kind))
+	}
+
+	//ParseReference splits on the separator, so a body containing it could not be read back
+	if body == "" || strings.Contains(body, referenceSeparator) {
+		return "", errors.New(fmt.Sprintf("reference generator - strategy %v returned body %q, which must be non-empty and without %q", g.Strategy.Name(), body, referenceSeparator))
+	}
+
+	env := byte(referenceEnvSandbox)
+	if g.Environment == EnvLive {
+		env = referenceEnvLive
+	}
+
+	reference := strings.Join([]string{string([]byte{env, kind}), g.Tenant, strings.ToUpper(purpose), body}, referenceSeparator)
+	if err := ValidateReference(reference); err != nil {
+		return "", err
+	}
+	return reference, nil
+}
+
+//ValidateReference checks that a reference fits the API's length and charset limits.
+//Only ASCII letters, digits and `-` are accepted.
+func ValidateReference(reference string) error {
+	if reference == "" {
+		return errors.New("transaction reference is required")
+	}
+
+	if len(reference) > ReferenceMaxLength {
+		return errors.New(fmt.Sprintf("transaction reference is longer than %v characters", ReferenceMaxLength))
+	}
+
+	for _, c := range reference {
+		if !isReferenceChar(c) {
+			return errors.New(fmt.Sprintf("transaction reference contains invalid character %q", c))
+		}
+	}
+	return nil
+}
+
+//ParseReference extracts the metadata embedded in a reference created by a ReferenceGenerator
+func ParseReference(reference string) (ReferenceMetadata, error) {
+	metadata := ReferenceMetadata{}
+	if err := ValidateReference(reference); err != nil {
+		return metadata, err
+	}
+
+	parts := strings.Split(reference, referenceSeparator)
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[3] == "" {
+		return metadata, errors.New("transaction reference was not created by a reference generator")
+	}
+
+	switch parts[0][0] {
+	case referenceEnvSandbox:
+		metadata.Environment = EnvSandbox
+	case referenceEnvLive:
+		metadata.Environment = EnvLive
+	default:
+		return metadata, errors.New(fmt.Sprintf("transaction reference has unknown environment marker %q", parts[0][0]))
+	}
+
+	metadata.Tenant = parts[1]
+	metadata.Purpose = parts[2]
+	metadata.Body = parts[3]
+
+	switch parts[0][1] {
+	case referenceKindULID:
+		metadata.Strategy = ReferenceStrategyULID
+		t, err := ulidTime(metadata.Body)
+		if err != nil {
+			return metadata, err
+		}
+		metadata.Time = t
+	case referenceKindSequence:
+		metadata.Strategy = ReferenceStrategySequence
+	case referenceKindUUID:
+		metadata.Strategy = ReferenceStrategyUUID
+		if len(metadata.Body) != uuidHexLength {
+			return metadata, errors.New("transaction reference has a malformed uuid body")
+		}
+	default:
+		if !isAlphanumeric(rune(parts[0][1])) {
+			return metadata, errors.New(fmt.Sprintf("transaction reference has invalid strategy marker %q", parts[0][1]))
+		}
+		metadata.Strategy = string(parts[0][1])
+	}
+
+	return metadata, nil
+}
+
+//NewULIDStrategy returns a strategy producing 26 character, time-sortable ULIDs.
+//References generated within the same millisecond are kept monotonic.
+func NewULIDStrategy() ReferenceStrategy {
+	return &ulidStrategy{}
+}
+
+func (s *ulidStrategy) Name() string {
+	return ReferenceStrategyULID
+}
+
+func (s *ulidStrategy) Marker() byte {
+	return referenceKindULID
+}
+
+func (s *ulidStrategy) Next() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	if now <= s.lastTime {
+		now = s.lastTime
+		if !incrementBytes(s.lastRand[:]) {
+			now++
+		}
+	} else {
+		if _, err := rand.Read(s.lastRand[:]); err != nil {
+			return "", err
+		}
+	}
+	s.lastTime = now
+
+	return encodeULID(now, s.lastRand), nil
+}
+
+//NewSequenceStrategy returns a strategy producing prefix+counter bodies, e.g. `PAY000001`.
+//The counter starts at start and is zero padded to width digits.
+func NewSequenceStrategy(prefix string, start uint64, width int) (ReferenceStrategy, error) {
+	for _, c := range prefix {
+		if !isAlphanumeric(c) {
+			return nil, errors.New(fmt.Sprintf("sequence prefix contains invalid character %q", c))
+		}
+	}
+
+	if width < 1 {
+		return nil, errors.New("sequence width cannot be less than 1")
+	}
+	return &sequenceStrategy{prefix: strings.ToUpper(prefix), next: start, width: width}, nil
+}
+
+func (s *sequenceStrategy) Name() string {
+	return ReferenceStrategySequence
+}
+
+func (s *sequenceStrategy) Marker() byte {
+	return referenceKindSequence
+}
+
+func (s *sequenceStrategy) Next() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := strconv.FormatUint(s.next, 10)
+	if len(n) > s.width {
+		return "", errors.New(fmt.Sprintf("sequence exhausted - %v does not fit in %v digits", s.next, s.width))
+	}
+	s.next++
+	return s.prefix + strings.Repeat("0", s.width-len(n)) + n, nil
+}
+
+//NewUUIDStrategy returns a strategy producing random (version 4) UUIDs without dashes
+func NewUUIDStrategy() ReferenceStrategy {
+	return uuidStrategy{}
+}
+
+func (s uuidStrategy) Name() string {
+	return ReferenceStrategyUUID
+}
+
+func (s uuidStrategy) Marker() byte {
+	return referenceKindUUID
+}
+
+func (s uuidStrategy) Next() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return strings.ToUpper(hex.EncodeToString(u[:])), nil
+}
+
+func validateReferenceSegment(name, value string, maxLength int) error {
+	if value == "" {
+		return errors.New(fmt.Sprintf("reference %v is required", name))
+	}
+
+	if len(value) > maxLength {
+		return errors.New(fmt.Sprintf("reference %v is longer than %v characters", name, maxLength))
+	}
+
+	for _, c := range value {
+		if !isAlphanumeric(c) {
+			return errors.New(fmt.Sprintf("reference %v contains invalid character %q", name, c))
+		}
+	}
+	return nil
+}
+
+func isAlphanumeric(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isReferenceChar(c rune) bool {
+	return isAlphanumeric(c) || c == '-'
+}
+
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(ms uint64, entropy [10]byte) string {
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> uint(40-8*i))
+	}
+	copy(id[6:], entropy[:])
+
+	//128 bits are encoded as 26 base32 characters, the first of which only carries 3 bits
+	out := make([]byte, ulidLength)
+	var acc uint32
+	bits := uint(2)
+	pos := 0
+	for _, b := range id {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockfordAlphabet[(acc>>bits)&0x1f]
+			pos++
+		}
+	}
+	return string(out)
+}
+
+func ulidTime(body string) (time.Time, error) {
+	if len(body) != ulidLength {
+		return time.Time{}, errors.New("transaction reference has a malformed ulid body")
+	}
+
+	var ms uint64
+	for _, c := range body[:10] {
+		i := strings.IndexRune(crockfordAlphabet, c)
+		if i < 0 {
+			return time.Time{}, errors.New(fmt.Sprintf("transaction reference has invalid ulid character %q", c))
+		}
+		ms = ms<<5 | uint64(i)
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC(), nil
+}
@@ -0,0 +1,258 @@
+package gowalletsafrica
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSyncPageSize int = 100
+)
+
+type (
+	//TransactionSource is satisfied by WalletsAfrica.Self
+	TransactionSource interface {
+		Transactions(currency Currency, transactionType TransactionType, take, skip int, dateFrom, dateTo string) (Transactions, error)
+	}
+
+	//Checkpoint records how far a currency has been synced: the timestamp of the newest delivered
+	//transaction and the fingerprints of the entries already delivered at that timestamp
+	Checkpoint struct {
+		LastDateTransacted time.Time `json:"last_date_transacted"`
+		Seen               []string  `json:"seen"`
+	}
+
+	//CheckpointStore persists checkpoints between runs.
+	//LoadCheckpoint returns a zero Checkpoint when none has been saved for the currency.
+	CheckpointStore interface {
+		LoadCheckpoint(currency Currency) (Checkpoint, error)
+		SaveCheckpoint(currency Currency, checkpoint Checkpoint) error
+	}
+
+	//SyncHandler receives each new transaction. Returning an error stops the sync and the
+	//transaction is delivered again on the next run.
+	SyncHandler func(currency Currency, transaction Transaction) error
+
+	//Syncer fetches transactions added since the last checkpoint and delivers each one to Handler once.
+	//The checkpoint is saved after every delivery, so only a crash between Handler returning and the
+	//checkpoint being saved can cause a redelivery; handlers should tolerate that.
+	Syncer struct {
+		Source    TransactionSource
+		Store     CheckpointStore
+		Handler   SyncHandler
+		PageSize  int    //Defaults to DefaultSyncPageSize
+		StartDate string //DateFormat date to start from when there is no checkpoint, empty for full history
+	}
+
+	//MemoryCheckpointStore keeps checkpoints in memory. Useful for tests and short lived processes.
+	MemoryCheckpointStore struct {
+		mu          sync.Mutex
+		checkpoints map[Currency]Checkpoint
+	}
+
+	//FileCheckpointStore keeps one JSON checkpoint file per currency in Dir
+	FileCheckpointStore struct {
+		Dir string
+	}
+)
+
+//NewSyncer creates a Syncer with the default page size
+func NewSyncer(source TransactionSource, store CheckpointStore, handler SyncHandler) *Syncer {
+	return &Syncer{Source: source, Store: store, Handler: handler, PageSize: DefaultSyncPageSize}
+}
+
+//Sync runs SyncCurrency for each currency in turn and returns the number of delivered
+//transactions per currency. It stops at the first error.
+func (s *Syncer) Sync(ctx context.Context, currencies ...Currency) (map[Currency]int, error) {
+	delivered := map[Currency]int{}
+	for _, currency := range currencies {
+		n, err := s.SyncCurrency(ctx, currency)
+		delivered[currency] = n
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+//SyncCurrency fetches transactions in currency from the checkpoint date onwards, skips the ones
+//already delivered and hands the rest to Handler from oldest to newest
+func (s *Syncer) SyncCurrency(ctx context.Context, currency Currency) (int, error) {
+	if s.Source == nil || s.Store == nil || s.Handler == nil {
+		return 0, errors.New("syncer - source, store and handler are required")
+	}
+
+	checkpoint, err := s.Store.LoadCheckpoint(currency)
+	if err != nil {
+		return 0, err
+	}
+
+	dateFrom := s.StartDate
+	if !checkpoint.LastDateTransacted.IsZero() {
+		//The API filters by day, so refetch the checkpoint day and rely on fingerprints for overlaps
+		dateFrom = checkpoint.LastDateTransacted.Format(DateFormat)
+	}
+
+	fetched, err := s.fetch(ctx, currency, dateFrom)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := map[string]bool{}
+	for _, fingerprint := range checkpoint.Seen {
+		seen[fingerprint] = true
+	}
+
+	delivered := 0
+	for _, t := range SortTransactions(fetched) {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+
+		date, err := t.Time()
+		if err != nil {
+			return delivered, err
+		}
+
+		if date.Before(checkpoint.LastDateTransacted) {
+			continue
+		}
+
+		fingerprint := t.Fingerprint()
+		if date.Equal(checkpoint.LastDateTransacted) && seen[fingerprint] {
+			continue
+		}
+
+		if err := s.Handler(currency, t); err != nil {
+			return delivered, err
+		}
+		delivered++
+
+		if date.After(checkpoint.LastDateTransacted) {
+			checkpoint = Checkpoint{LastDateTransacted: date}
+			seen = map[string]bool{}
+		}
+		checkpoint.Seen = append(checkpoint.Seen, fingerprint)
+		seen[fingerprint] = true
+
+		if err := s.Store.SaveCheckpoint(currency, checkpoint); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+func (s *Syncer) fetch(ctx context.Context, currency Currency, dateFrom string) (Transactions, error) {
+	pageSize := s.PageSize
+	if pageSize < 1 {
+		pageSize = DefaultSyncPageSize
+	}
+
+	all := Transactions{}
+	for skip := 0; ; skip += pageSize {
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+
+		page, err := s.Source.Transactions(currency, TransactionTypeAll, pageSize, skip, dateFrom, "")
+		if err != nil {
+			return all, err
+		}
+		all = append(all, page...)
+
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+//NewMemoryCheckpointStore creates an empty in-memory store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: map[Currency]Checkpoint{}}
+}
+
+func (m *MemoryCheckpointStore) LoadCheckpoint(currency Currency) (Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyCheckpoint(m.checkpoints[currency]), nil
+}
+
+func (m *MemoryCheckpointStore) SaveCheckpoint(currency Currency, checkpoint Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkpoints[currency] = copyCheckpoint(checkpoint)
+	return nil
+}
+
+func (f FileCheckpointStore) LoadCheckpoint(currency Currency) (Checkpoint, error) {
+	checkpoint := Checkpoint{}
+	raw, err := ioutil.ReadFile(f.path(currency))
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+
+	if err := json.Unmarshal(raw, &checkpoint); err != nil {
+		return checkpoint, errors.New(fmt.Sprintf("malformed checkpoint for %v - %v", currency, err))
+	}
+	return checkpoint, nil
+}
+
+//SaveCheckpoint writes to a temporary file and renames it so a crash never leaves a partial checkpoint
+func (f FileCheckpointStore) SaveCheckpoint(currency Currency, checkpoint Checkpoint) error {
+	raw, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(f.path(currency), raw)
+}
+
+//writeFileAtomic writes raw to a temporary file next to path and renames it over path, so readers
+//never see a partial file. Missing directories are created; the file is readable by the owner only.
+func writeFileAtomic(path string, raw []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f FileCheckpointStore) path(currency Currency) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("checkpoint-%v.json", strings.ToLower(string(currency))))
+}
+
+func copyCheckpoint(checkpoint Checkpoint) Checkpoint {
+	checkpoint.Seen = append([]string(nil), checkpoint.Seen...)
+	return checkpoint
+}
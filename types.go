@@ -14,6 +14,8 @@ type (
 		APIURL     string
 		secretKey  string
 		publicKey  string
+
+		validateAccountNumbers bool
 	}
 
 	self struct {
@@ -49,6 +51,15 @@ type (
 		PublicKey      string
 		SecretKey      string
 		RequestTimeout time.Duration
+		//ValidateAccountNumbers makes Payouts.BankTransfer check account numbers with ValidateNUBAN against
+		//the bank code before sending. Leave it off if you pay banks whose API code is not their NUBAN code.
+		ValidateAccountNumbers bool
+	}
+
+	//RequestNotSentError wraps errors from calls that failed before their request reached the API, e.g. on
+	//invalid arguments, so nothing happened on the account
+	RequestNotSentError struct {
+		Err error
 	}
 
 	Transaction struct {
@@ -105,6 +116,15 @@ type (
 		Message         string
 	}
 
+	BankTransferResult struct {
+		TransactionReference string
+		AmountCharged        float64
+		RecipientName        string
+		SessionId            string
+		ResponseCode         string
+		Message              string
+	}
+
 	payloadBody  map[string]interface{}
 	responseBody map[string]interface{}
 
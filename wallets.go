@@ -87,12 +87,12 @@ func (w *wallets) Credit(amount float64, transactionReference, phoneNumber strin
 
 	payload, err := json.Marshal(payloadValues)
 	if err != nil {
-		return result, err
+		return result, notSent(err)
 	}
 
 	req, err := http.NewRequest("POST", fmt.Sprintf("%v/wallet/credit", w.APIURL), bytes.NewReader(payload))
 	if err != nil {
-		return result, err
+		return result, notSent(err)
 	}
 
 	resp, err := w.makeRequest(req)
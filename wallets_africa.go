@@ -89,6 +89,8 @@ func newBase(config Config) *base {
 		HTTPClient: &http.Client{Timeout: config.RequestTimeout},
 		secretKey:  config.SecretKey,
 		publicKey:  config.PublicKey,
+
+		validateAccountNumbers: config.ValidateAccountNumbers,
 	}
 
 	switch config.Environment {
@@ -101,6 +103,19 @@ func newBase(config Config) *base {
 	return b
 }
 
+func (e *RequestNotSentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestNotSentError) Unwrap() error {
+	return e.Err
+}
+
+//notSent marks err as raised before a request was sent
+func notSent(err error) error {
+	return &RequestNotSentError{Err: err}
+}
+
 func (b *base) makeRequest(req *http.Request) (*http.Response, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", b.publicKey))
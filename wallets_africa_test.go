@@ -1,12 +1,29 @@
 package gowalletsafrica
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var client *WalletsAfrica
@@ -59,66 +76,2116 @@ func TestIdentity_ResolveBVN(t *testing.T) {
 	assert.Equal(t, "DOE", bvnData.LastName)
 }
 
+func TestValidateBVN(t *testing.T) {
+	assert.Nil(t, ValidateBVN("22231485915"))
+	assert.NotNil(t, ValidateBVN(""))
+	assert.NotNil(t, ValidateBVN("2223148591"))
+	assert.NotNil(t, ValidateBVN("2223148591a"))
+
+	_, err := client.Identity.ResolveBVN("222314859")
+	assert.Equal(t, "BVN number must be 11 digits", err.Error())
+}
+
 func TestIdentity_ResolveBVNDetails(t *testing.T) {
 	bvnData, _ := client.Identity.ResolveBVNDetails("22231485915")
 	assert.Equal(t, "JOHN", bvnData.FirstName)
 	assert.Equal(t, "DOE", bvnData.LastName)
 }
 
-//Airtime Tests
-func TestAirtime_GetProviders(t *testing.T) {
-	providers, _ := client.Airtime.GetProviders()
-	assert.Equal(t, 4, len(providers))
-	assert.Equal(t, "airtel", providers[0].Code)
-	assert.Equal(t, "Airtel", providers[0].Name)
+//Airtime Tests
+func TestAirtime_GetProviders(t *testing.T) {
+	providers, _ := client.Airtime.GetProviders()
+	assert.Equal(t, 4, len(providers))
+	assert.Equal(t, "airtel", providers[0].Code)
+	assert.Equal(t, "Airtel", providers[0].Name)
+}
+
+func TestAirtimeProviders_ForPhoneNumber(t *testing.T) {
+	providers, _ := client.Airtime.GetProviders()
+
+	provider, err := providers.ForPhoneNumber("+234 809 123 4567")
+	assert.Nil(t, err)
+	assert.Equal(t, "etisalat", provider.Code)
+
+	provider, _ = providers.ForPhoneNumber("08031234567")
+	assert.Equal(t, "mtn", provider.Code)
+
+	_, err = providers.ForPhoneNumber("+254712345678")
+	assert.NotNil(t, err)
+}
+
+func TestGetResponseCodeAndMessage(t *testing.T) {
+	r := responseBody{
+		"Response": map[string]interface{}{
+			"ResponseCode": "200",
+			"Message":      "Balance Retrieved successfully",
+		},
+	}
+
+	assert.Equal(t, "200", client.Self.getResponseCode(r))
+	assert.Equal(t, "Balance Retrieved successfully", client.Self.getResponseMessage(r))
+}
+
+//Payouts Tests
+func TestPayouts_GetBanks(t *testing.T) {
+	banks, _ := client.Payouts.GetBanks()
+	assert.Equal(t, 2, len(banks))
+	assert.Equal(t, "044", banks[0].BankCode)
+	assert.Equal(t, "000017", banks[1].BankSortCode)
+}
+
+func TestPayouts_BankDetails(t *testing.T) {
+	details, _ := client.Payouts.BankDetails("2578615312")
+	assert.Equal(t, "Gtbank Plc", details.Bank)
+	assert.Equal(t, "0200556677", details.AccountNumber)
+	assert.Equal(t, 10.00, details.Amount)
+}
+
+func TestPayouts_BankTransfer(t *testing.T) {
+	result, err := client.Payouts.BankTransfer(1000.0, "044", "0690000032", "John Doe", "Salary", "SQ-ACME-PAY-0001")
+	assert.Nil(t, err)
+	assert.Equal(t, "SQ-ACME-PAY-0001", result.TransactionReference)
+	assert.Equal(t, 1010.75, result.AmountCharged)
+	assert.Equal(t, "JOHN DOE", result.RecipientName)
+	assert.Equal(t, "200", result.ResponseCode)
+
+	_, err = client.Payouts.BankTransfer(0, "044", "0690000032", "John Doe", "Salary", "SQ-ACME-PAY-0001")
+	assert.IsType(t, &RequestNotSentError{}, err)
+
+	//Account numbers are only checked when enabled
+	_, err = client.Payouts.BankTransfer(1000.0, "044", "0690000031", "John Doe", "Salary", "SQ-ACME-PAY-0001")
+	assert.Nil(t, err)
+
+	config := DefaultConfig
+	config.ValidateAccountNumbers = true
+	validating, _ := New(config)
+	validating.Payouts.APIURL = client.Payouts.APIURL
+	_, err = validating.Payouts.BankTransfer(1000.0, "044", "0690000031", "John Doe", "Salary", "SQ-ACME-PAY-0001")
+	assert.Equal(t, "account number 0690000031 is not valid for bank code 044", err.Error())
+	_, err = validating.Payouts.BankTransfer(1000.0, "044", "0690000032", "John Doe", "Salary", "SQ-ACME-PAY-0001")
+	assert.Nil(t, err)
+}
+
+//Wallets Tests
+func TestWallets_Generate(t *testing.T) {
+	wallet, _ := client.Wallets.Generate(CurrencyNigeria, "John", "Doe", "johndoe@example.com", "1992-10-03")
+
+	assert.Equal(t, "johndoe@example.com", wallet.Email)
+	assert.Equal(t, "John", wallet.FirstName)
+	assert.Equal(t, "Doe", wallet.LastName)
+	assert.Equal(t, "1992-10-03", wallet.DateOfBirth)
+}
+
+func TestWallets_Credit(t *testing.T) {
+	result, _ := client.Wallets.Credit(1000.0, "9821358010", "08112498539")
+
+	assert.Equal(t, 1000.0, result.AmountCredited)
+	assert.Equal(t, 1054.00, result.RecipientWalletBalance)
+	assert.Equal(t, 7305140.16, result.SenderWalletBalance)
+}
+
+//Reference Tests
+func TestReferenceGenerator_Generate(t *testing.T) {
+	generator, err := NewReferenceGenerator(EnvSandbox, "acme", NewULIDStrategy())
+	assert.Nil(t, err)
+
+	first, err := generator.Generate("payout")
+	assert.Nil(t, err)
+	second, _ := generator.Generate("payout")
+	assert.True(t, first < second)
+	assert.Nil(t, ValidateReference(first))
+
+	metadata, err := ParseReference(first)
+	assert.Nil(t, err)
+	assert.Equal(t, EnvSandbox, metadata.Environment)
+	assert.Equal(t, ReferenceStrategyULID, metadata.Strategy)
+	assert.Equal(t, "ACME", metadata.Tenant)
+	assert.Equal(t, "PAYOUT", metadata.Purpose)
+	assert.WithinDuration(t, time.Now(), metadata.Time, time.Minute)
+
+	//Test Segment Validations
+	_, err = NewReferenceGenerator("staging", "acme", NewULIDStrategy())
+	assert.NotNil(t, err)
+	_, err = generator.Generate("pay_out")
+	assert.NotNil(t, err)
+}
+
+func TestReferenceStrategies(t *testing.T) {
+	sequence, err := NewSequenceStrategy("pay", 98, 2)
+	assert.Nil(t, err)
+	generator, _ := NewReferenceGenerator(EnvLive, "acme", sequence)
+
+	reference, _ := generator.Generate("credit")
+	assert.Equal(t, "LQ-ACME-CREDIT-PAY98", reference)
+	reference, _ = generator.Generate("credit")
+	assert.Equal(t, "LQ-ACME-CREDIT-PAY99", reference)
+	_, err = generator.Generate("credit")
+	assert.NotNil(t, err)
+
+	generator, _ = NewReferenceGenerator(EnvLive, "acme", NewUUIDStrategy())
+	reference, _ = generator.Generate("credit")
+	metadata, err := ParseReference(reference)
+	assert.Nil(t, err)
+	assert.Equal(t, ReferenceStrategyUUID, metadata.Strategy)
+	assert.Equal(t, byte('4'), metadata.Body[12])
+
+	generator, _ = NewReferenceGenerator(EnvLive, "acme", fixedReferenceStrategy{marker: 'F'})
+	reference, err = generator.Generate("credit")
+	assert.Nil(t, err)
+	assert.Equal(t, "LF-ACME-CREDIT-ORDER42", reference)
+	metadata, err = ParseReference(reference)
+	assert.Nil(t, err)
+	assert.Equal(t, "F", metadata.Strategy)
+
+	generator, _ = NewReferenceGenerator(EnvLive, "acme", fixedReferenceStrategy{marker: '_'})
+	_, err = generator.Generate("credit")
+	assert.NotNil(t, err)
+
+	//Bodies with the separator would generate references ParseReference cannot read
+	generator, _ = NewReferenceGenerator(EnvLive, "acme", fixedReferenceStrategy{marker: 'F', body: "ORDER-42"})
+	_, err = generator.Generate("credit")
+	assert.EqualError(t, err, `reference generator - strategy fixed returned body "ORDER-42", which must be non-empty and without "-"`)
+}
+
+type fixedReferenceStrategy struct {
+	marker byte
+	body   string
+}
+
+func (s fixedReferenceStrategy) Name() string {
+	return "fixed"
+}
+
+func (s fixedReferenceStrategy) Marker() byte {
+	return s.marker
+}
+
+func (s fixedReferenceStrategy) Next() (string, error) {
+	if s.body == "" {
+		return "ORDER42", nil
+	}
+	return s.body, nil
+}
+
+func TestParseReference(t *testing.T) {
+	_, err := ParseReference("9821358010")
+	assert.NotNil(t, err)
+
+	_, err = ParseReference("XU-ACME-PAY-01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.NotNil(t, err)
+
+	metadata, err := ParseReference("SU-ACME-PAY-01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1469922850259), metadata.Time.UnixNano()/int64(time.Millisecond))
+
+	assert.NotNil(t, ValidateReference("ref with spaces"))
+	assert.NotNil(t, ValidateReference(strings.Repeat("A", ReferenceMaxLength+1)))
+}
+
+//Export Tests
+var exportTransactions = Transactions{
+	{Amount: 250.5, Currency: "NGN", Category: "Wallet Transfer", Narration: "Refund, order 12", DateTransacted: "7/18/2020 6:28:59 PM", PreviousBalance: 1000, NewBalance: 1250.5, Type: "Credit"},
+	{Amount: 100, Currency: "NGN", Category: "Bank Transfer", Narration: "Payout", DateTransacted: "7/17/2020 9:01:00 AM", PreviousBalance: 1100, NewBalance: 1000, Type: "Debit"},
+}
+
+func TestTransaction_SignedAmount(t *testing.T) {
+	amount, err := exportTransactions[1].SignedAmount()
+	assert.Nil(t, err)
+	assert.Equal(t, -100.0, amount)
+
+	_, err = Transaction{Type: "Reversal"}.SignedAmount()
+	assert.NotNil(t, err)
+
+	opening, closing, err := StatementBalances(exportTransactions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1100.0, opening)
+	assert.Equal(t, 1250.5, closing)
+}
+
+func TestExport_CSV(t *testing.T) {
+	out := &strings.Builder{}
+	writer, err := NewCSVWriter(out, ExportOptions{Columns: []ExportColumn{ExportColumnDate, ExportColumnSignedAmount, ExportColumnNarration}})
+	assert.Nil(t, err)
+	assert.Nil(t, ExportTransactions(writer, exportTransactions))
+	assert.Equal(t, "date,signed_amount,narration\n2020-07-18 18:28:59,250.50,\"Refund, order 12\"\n2020-07-17 09:01:00,-100.00,Payout\n", out.String())
+
+	_, err = NewCSVWriter(out, ExportOptions{Columns: []ExportColumn{"fee"}})
+	assert.NotNil(t, err)
+}
+
+func TestExport_JSONLines(t *testing.T) {
+	out := &strings.Builder{}
+	writer, _ := NewJSONLinesWriter(out, ExportOptions{Columns: []ExportColumn{ExportColumnType, ExportColumnAmount, ExportColumnNewBalance}})
+	assert.Nil(t, ExportTransactions(writer, exportTransactions))
+	assert.Equal(t, "{\"type\":\"Credit\",\"amount\":250.50,\"new_balance\":1250.50}\n{\"type\":\"Debit\",\"amount\":100.00,\"new_balance\":1000.00}\n", out.String())
+
+	out.Reset()
+	writer, _ = NewJSONLinesWriter(out, ExportOptions{Columns: []ExportColumn{ExportColumnType, ExportColumnSignedAmount}})
+	assert.NotNil(t, writer.Write(Transaction{Amount: 10, Type: "Fee"}))
+	assert.Nil(t, writer.Write(exportTransactions[1]))
+	assert.Nil(t, writer.Close())
+	assert.Equal(t, "{\"type\":\"Debit\",\"signed_amount\":-100.00}\n", out.String(), "a failed write leaves no partial line")
+}
+
+func TestExport_OFX(t *testing.T) {
+	out := &strings.Builder{}
+	writer, err := NewOFXWriter(out, ExportOptions{AccountID: "1023236949"})
+	assert.Nil(t, err)
+	assert.Nil(t, ExportTransactions(writer, exportTransactions))
+
+	ofx := out.String()
+	assert.Contains(t, ofx, `<?OFX OFXHEADER="200" VERSION="220"`)
+	assert.Contains(t, ofx, "<DTSTART>20200717090100</DTSTART>")
+	assert.Contains(t, ofx, "<TRNAMT>-100.00</TRNAMT>")
+	assert.Contains(t, ofx, "<BALAMT>1250.50</BALAMT>")
+	assert.Contains(t, ofx, "<VALUE>1100.00</VALUE>")
+	assert.True(t, strings.Index(ofx, "<TRNTYPE>DEBIT</TRNTYPE>") < strings.Index(ofx, "<TRNTYPE>CREDIT</TRNTYPE>"))
+
+	writer, _ = NewOFXWriter(out, ExportOptions{AccountID: "1023236949"})
+	assert.Nil(t, writer.Write(exportTransactions[0]))
+	assert.NotNil(t, writer.Write(Transaction{Currency: "USD", DateTransacted: "7/18/2020 6:28:59 PM", Type: "Credit"}))
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, 2, CurrencyMinorUnits(CurrencyNigeria))
+	assert.Equal(t, 0, CurrencyMinorUnits("ugx"))
+	assert.Equal(t, 3, CurrencyMinorUnits("TND"))
+	assert.Equal(t, 2, CurrencyMinorUnits("ZAR"))
+
+	assert.Equal(t, "1250.50", FormatAmount(1250.5, CurrencyKenya))
+	assert.Equal(t, "1251", FormatAmount(1250.6, "UGX"))
+	assert.Equal(t, "12.345", FormatAmount(12.345, "TND"))
+}
+
+//Camt053 Tests
+func TestGenerateCamt053(t *testing.T) {
+	options := Camt053Options{
+		MessageID:   "MSG-1",
+		StatementID: "STMT-2020-07",
+		AccountID:   "1023236949",
+		Currency:    CurrencyNigeria,
+		From:        time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2020, 7, 31, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   time.Date(2020, 8, 1, 8, 0, 0, 0, time.UTC),
+	}
+	transactions := append(Transactions{{Amount: 5, Currency: "USD", DateTransacted: "7/18/2020 6:28:59 PM", Type: "Credit"}}, exportTransactions...)
+
+	document, err := GenerateCamt053(transactions, options)
+	assert.Nil(t, err)
+
+	//Schema shape - element order inside Stmt and Ntry must follow the camt.053.001.02 sequence
+	assert.Equal(t, []string{"Id", "CreDtTm", "FrToDt", "Acct", "Bal", "Bal", "TxsSummry", "Ntry", "Ntry"}, xmlChildren(document, "Stmt"))
+	assert.Equal(t, []string{"NtryRef", "Amt", "CdtDbtInd", "Sts", "BookgDt", "ValDt", "BkTxCd", "NtryDtls", "AddtlNtryInf"}, xmlChildren(document, "Ntry"))
+
+	parsed := struct {
+		XMLName  xml.Name
+		Balances []struct {
+			Code      string `xml:"Tp>CdOrPrtry>Cd"`
+			Amount    string `xml:"Amt"`
+			Indicator string `xml:"CdtDbtInd"`
+		} `xml:"BkToCstmrStmt>Stmt>Bal"`
+		Entries []struct {
+			Amount    string `xml:"Amt"`
+			Indicator string `xml:"CdtDbtInd"`
+			Booked    string `xml:"BookgDt>DtTm"`
+			Code      string `xml:"BkTxCd>Prtry>Cd"`
+			Narration string `xml:"NtryDtls>TxDtls>RmtInf>Ustrd"`
+		} `xml:"BkToCstmrStmt>Stmt>Ntry"`
+		Net string `xml:"BkToCstmrStmt>Stmt>TxsSummry>TtlNtries>TtlNetNtryAmt"`
+	}{}
+	assert.Nil(t, xml.Unmarshal(document, &parsed))
+	assert.Equal(t, Camt053Namespace, parsed.XMLName.Space)
+	assert.Equal(t, "OPBD", parsed.Balances[0].Code)
+	assert.Equal(t, "1100.00", parsed.Balances[0].Amount)
+	assert.Equal(t, "CLBD", parsed.Balances[1].Code)
+	assert.Equal(t, "1250.50", parsed.Balances[1].Amount)
+	assert.Equal(t, "100.00", parsed.Entries[0].Amount)
+	assert.Equal(t, "DBIT", parsed.Entries[0].Indicator)
+	assert.Equal(t, "2020-07-17T09:01:00", parsed.Entries[0].Booked)
+	assert.Equal(t, "Bank Transfer", parsed.Entries[0].Code)
+	assert.Equal(t, "Refund, order 12", parsed.Entries[1].Narration)
+	assert.Equal(t, "150.50", parsed.Net)
+
+	uncategorised := Transactions{{Amount: 5, Currency: "NGN", DateTransacted: "7/18/2020 6:28:59 PM", Type: "Credit", PreviousBalance: 0, NewBalance: 5}}
+	document, err = GenerateCamt053(uncategorised, options)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(document), "<Cd></Cd>")
+	parsed.Entries = nil
+	assert.Nil(t, xml.Unmarshal(document, &parsed))
+	assert.Equal(t, "NOTPROVIDED", parsed.Entries[0].Code)
+
+	//Test Range Validations
+	options.From = time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC)
+	options.To = time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC)
+	_, err = GenerateCamt053(transactions, options)
+	assert.NotNil(t, err)
+
+	options.To = options.From.Add(-time.Hour)
+	_, err = GenerateCamt053(transactions, options)
+	assert.NotNil(t, err)
+}
+
+//xmlChildren returns the names of the direct children of the first element called parent
+func xmlChildren(document []byte, parent string) []string {
+	children := []string{}
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	depth := -1
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return children
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if depth < 0 && el.Name.Local == parent {
+				depth = 0
+				continue
+			}
+			if depth >= 0 {
+				if depth == 0 {
+					children = append(children, el.Name.Local)
+				}
+				depth++
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				return children
+			}
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+}
+
+//MT940 Tests
+func TestWriteMT940(t *testing.T) {
+	options := MT940Options{TransactionReference: "STMT202007", AccountID: "1023236949", StatementNumber: 7, Currency: CurrencyNigeria}
+	out := &strings.Builder{}
+	assert.Nil(t, WriteMT940(out, exportTransactions, options))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\r\n"), "\r\n")
+	assert.Equal(t, []string{
+		":20:STMT202007",
+		":25:1023236949",
+		":28C:7/1",
+		":60F:C200717NGN1100,00",
+		":61:2007170717D100,00NTRFNONREF//" + exportTransactions[1].Fingerprint()[:16],
+		":86:Bank Transfer Payout",
+		":61:2007180718C250,50NTRFNONREF//" + exportTransactions[0].Fingerprint()[:16],
+		":86:Wallet Transfer Refund, order 12",
+		":62F:C200718NGN1250,50",
+		"-",
+	}, lines)
+
+	//Test Paging
+	out.Reset()
+	options.MaxEntriesPerPage = 1
+	assert.Nil(t, WriteMT940(out, exportTransactions, options))
+	assert.Contains(t, out.String(), ":28C:7/1\r\n:60F:C200717NGN1100,00")
+	assert.Contains(t, out.String(), ":62M:C200717NGN1000,00\r\n-\r\n")
+	assert.Contains(t, out.String(), ":28C:7/2\r\n:60M:C200718NGN1000,00")
+	assert.Contains(t, out.String(), ":62F:C200718NGN1250,50")
+
+	//Test Validations
+	options.Currency = CurrencyKenya
+	assert.NotNil(t, WriteMT940(out, exportTransactions, options))
+	options.StatementNumber = 0
+	assert.NotNil(t, WriteMT940(out, exportTransactions, options))
+
+	amount, _ := FormatMT940Amount(-1234567.5, CurrencyUSA)
+	assert.Equal(t, "1234567,50", amount)
+}
+
+//Balance Chain Tests
+func TestVerifyBalanceChain(t *testing.T) {
+	report := VerifyBalanceChain(exportTransactions)
+	assert.True(t, report.OK())
+	assert.Equal(t, "Debit", report.Transactions[0].Type)
+	assert.Equal(t, 1100.0, report.OpeningBalance)
+	assert.Equal(t, 1250.5, report.ClosingBalance)
+
+	broken := Transactions{
+		{Amount: 100, Currency: "NGN", DateTransacted: "7/17/2020 9:01:00 AM", PreviousBalance: 1100, NewBalance: 1000, Type: "Debit"},
+		{Amount: 100, Currency: "NGN", DateTransacted: "7/17/2020 9:01:00 AM", PreviousBalance: 1100, NewBalance: 1000, Type: "Debit"},
+		{Amount: 50, Currency: "NGN", DateTransacted: "7/18/2020 9:01:00 AM", PreviousBalance: 1020, NewBalance: 1060, Type: "Credit"},
+		{Amount: 5, Currency: "NGN", DateTransacted: "yesterday", PreviousBalance: 1060, NewBalance: 1065, Type: "Credit"},
+	}
+	report = VerifyBalanceChain(broken)
+	assert.False(t, report.OK())
+	assert.Equal(t, 1, len(report.FindingsOf(FindingDuplicate)))
+	assert.Equal(t, 1, len(report.FindingsOf(FindingAmountMismatch)))
+	assert.Equal(t, 1070.0, report.FindingsOf(FindingAmountMismatch)[0].Expected)
+	assert.Equal(t, 1, len(report.FindingsOf(FindingGap)))
+	assert.Equal(t, 1000.0, report.FindingsOf(FindingGap)[0].Expected)
+	assert.Equal(t, 1, len(report.FindingsOf(FindingInvalidEntry)))
+}
+
+func TestSelf_VerifyBalanceChain(t *testing.T) {
+	history := Transactions{
+		{Amount: 0.16, Currency: "NGN", DateTransacted: "7/18/2020 9:00:00 AM", PreviousBalance: 900, NewBalance: 900.16, Type: "Credit"},
+		{Amount: 20, Currency: "NGN", DateTransacted: "7/18/2020 9:00:00 AM", PreviousBalance: 900.16, NewBalance: 880.16, Type: "Debit"},
+	}
+	report, err := client.Self.VerifyBalanceChain(CurrencyNigeria, Transactions{history[1], history[0]})
+	assert.Nil(t, err)
+	assert.True(t, report.OK())
+
+	history[1].NewBalance, history[1].Amount = 890.16, 10
+	report, _ = client.Self.VerifyBalanceChain(CurrencyNigeria, history)
+	assert.Equal(t, 1, len(report.FindingsOf(FindingBalanceMismatch)))
+	assert.Equal(t, 880.16, report.Findings[0].Expected)
+}
+
+//Reconciliation Tests
+type mockLedger []LedgerEntry
+
+func (m mockLedger) LedgerEntries(currency Currency, from, to time.Time) ([]LedgerEntry, error) {
+	return m, nil
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2020, 7, d, h, 0, 0, 0, time.UTC) }
+	ledger := mockLedger{
+		{Reference: "LU-ACME-CREDIT-01ARZ3NDEKTSV4RRFFQ69G5FAV", Amount: 250.5, Currency: CurrencyNigeria, Type: "Credit", Date: day(18, 18)},
+		{Reference: "2578615312", Amount: 10, Currency: CurrencyNigeria, Type: "Debit", Date: day(15, 13)},
+		{Reference: "PAY-2", Amount: 100, Currency: CurrencyNigeria, Type: "Debit", Date: day(17, 8)},
+		{Reference: "PAY-3", Amount: 75, Currency: CurrencyNigeria, Type: "Debit", Date: day(10, 8)},
+		{Reference: "PAY-4", Amount: 80, Currency: CurrencyNigeria, Type: "Debit", Date: time.Date(2020, 6, 30, 8, 0, 0, 0, time.UTC)},
+		{Reference: "PAY-5", Amount: 5, Currency: CurrencyUSA, Type: "Debit", Date: day(10, 8)},
+	}
+
+	transactions := append(Transactions{
+		{Amount: 250.5, Currency: "NGN", Narration: "Credit LU-ACME-CREDIT-01ARZ3NDEKTSV4RRFFQ69G5FAV", DateTransacted: "7/18/2020 6:30:00 PM", Type: "Credit"},
+		{Amount: 42, Currency: "NGN", Narration: "Card funding", DateTransacted: "7/19/2020 6:30:00 PM", Type: "Credit"},
+		{Amount: 60, Currency: "NGN", Narration: "Card funding", DateTransacted: "8/1/2020 6:30:00 PM", Type: "Credit"},
+		{Amount: 7, Currency: "USD", Narration: "Card funding", DateTransacted: "7/19/2020 6:30:00 PM", Type: "Credit"},
+	}, exportTransactions[1])
+	records, err := ProviderRecordsFromTransactions(transactions, ReferenceFromNarration)
+	assert.Nil(t, err)
+
+	details, err := ProviderRecordsFromBankDetails(map[string]BankDetail{"2578615312": {Amount: 12, DateTransferred: "7/15/2020 1:45:31 PM"}})
+	assert.Nil(t, err)
+
+	report, err := Reconciler{Ledger: ledger}.Reconcile(CurrencyNigeria, day(1, 0), day(31, 0), append(records, details...))
+	assert.Nil(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, 2, len(report.Matched))
+	assert.Equal(t, MatchedByReference, report.Matched[0].MatchedBy)
+	assert.Equal(t, MatchedByAmountDate, report.Matched[1].MatchedBy)
+	assert.Equal(t, 1, len(report.AmountMismatch))
+	assert.Equal(t, 2.0, report.AmountMismatch[0].Difference)
+	assert.Equal(t, 1, len(report.MissingOnProvider), "entries outside the currency or period are left out")
+	assert.Equal(t, "PAY-3", report.MissingOnProvider[0].Reference)
+	assert.Equal(t, 1, len(report.MissingInternally), "records outside the currency or period are left out")
+	assert.Equal(t, 42.0, report.MissingInternally[0].Amount)
+
+	out := &strings.Builder{}
+	assert.Nil(t, report.WriteCSV(out))
+	assert.Contains(t, out.String(), "amount_mismatch,2578615312,NGN,Debit,10.00,12.00,2.00")
+	assert.Contains(t, out.String(), "missing_on_provider,PAY-3,NGN,Debit,75.00,,")
+
+	out.Reset()
+	assert.Nil(t, report.WriteJSON(out))
+	assert.Contains(t, out.String(), `"missing_internally": [`)
+}
+
+//Sync Tests
+type mockTransactionSource struct {
+	transactions Transactions
+	calls        int
+}
+
+func (m *mockTransactionSource) Transactions(currency Currency, transactionType TransactionType, take, skip int, dateFrom, dateTo string) (Transactions, error) {
+	m.calls++
+	page := Transactions{}
+	for i := skip; i < len(m.transactions) && i < skip+take; i++ {
+		page = append(page, m.transactions[i])
+	}
+	return page, nil
+}
+
+func TestSyncer_SyncCurrency(t *testing.T) {
+	delivered := Transactions{}
+	handler := func(currency Currency, transaction Transaction) error {
+		delivered = append(delivered, transaction)
+		return nil
+	}
+
+	store := NewMemoryCheckpointStore()
+	syncer := NewSyncer(client.Self, store, handler)
+	n, err := syncer.SyncCurrency(context.Background(), CurrencyNigeria)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "7/18/2020 11:38:27 AM", delivered[0].DateTransacted)
+
+	n, _ = syncer.SyncCurrency(context.Background(), CurrencyNigeria)
+	assert.Equal(t, 0, n)
+
+	//New entries at the checkpoint timestamp and later are delivered once, across pages
+	source := &mockTransactionSource{transactions: append(Transactions{
+		{Amount: 3, Currency: "NGN", DateTransacted: "7/18/2020 6:28:59 PM", PreviousBalance: 7806790.16, NewBalance: 7806793.16, Type: "Credit"},
+		{Amount: 9, Currency: "NGN", DateTransacted: "7/19/2020 8:00:00 AM", PreviousBalance: 7806793.16, NewBalance: 7806802.16, Type: "Credit"},
+	}, delivered...)}
+	syncer = &Syncer{Source: source, Store: store, Handler: handler, PageSize: 2}
+	counts, err := syncer.Sync(context.Background(), CurrencyNigeria)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counts[CurrencyNigeria])
+	assert.Equal(t, 3, source.calls)
+	assert.Equal(t, 9.0, delivered[3].Amount)
+
+	checkpoint, _ := store.LoadCheckpoint(CurrencyNigeria)
+	assert.Equal(t, 1, len(checkpoint.Seen))
+
+	//A failing handler leaves the entry to be delivered on the next run
+	source.transactions = append(source.transactions, Transaction{Amount: 1, Currency: "NGN", DateTransacted: "7/20/2020 8:00:00 AM", Type: "Credit"})
+	syncer.Handler = func(currency Currency, transaction Transaction) error { return errors.New("unavailable") }
+	counts, err = syncer.Sync(context.Background(), CurrencyNigeria)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, counts[CurrencyNigeria])
+	syncer.Handler = handler
+	counts, _ = syncer.Sync(context.Background(), CurrencyNigeria)
+	assert.Equal(t, 1, counts[CurrencyNigeria])
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "checkpoints")
+	defer os.RemoveAll(dir)
+
+	store := FileCheckpointStore{Dir: dir}
+	checkpoint, err := store.LoadCheckpoint(CurrencyGhana)
+	assert.Nil(t, err)
+	assert.True(t, checkpoint.LastDateTransacted.IsZero())
+
+	saved := Checkpoint{LastDateTransacted: time.Date(2020, 7, 18, 18, 28, 59, 0, time.UTC), Seen: []string{"abc"}}
+	assert.Nil(t, store.SaveCheckpoint(CurrencyGhana, saved))
+	checkpoint, err = store.LoadCheckpoint(CurrencyGhana)
+	assert.Nil(t, err)
+	assert.Equal(t, saved, checkpoint)
+}
+
+//Fingerprint Tests
+func TestTransaction_Fingerprint(t *testing.T) {
+	original := exportTransactions[0]
+	refetched := original
+	refetched.Currency, refetched.Type, refetched.Amount = "ngn", "CREDIT", 250.50000000001
+	assert.Equal(t, original.Fingerprint(), refetched.Fingerprint())
+	assert.Equal(t, 64, len(original.Fingerprint()))
+
+	changed := original
+	changed.NewBalance = 1250.51
+	assert.NotEqual(t, original.Fingerprint(), changed.Fingerprint())
+
+	changed = original
+	changed.Narration, changed.Category = original.Narration+"\x1f", "Other"
+	assert.NotEqual(t, original.Fingerprint(), changed.Fingerprint())
+}
+
+func TestTransactions_Dedup(t *testing.T) {
+	overlapping := append(Transactions{exportTransactions[1]}, exportTransactions...)
+	unique := overlapping.Dedup()
+	assert.Equal(t, 2, len(unique))
+	assert.Equal(t, exportTransactions[1], unique[0])
+
+	out := &strings.Builder{}
+	writer, _ := NewCSVWriter(out, ExportOptions{Columns: []ExportColumn{ExportColumnAmount}})
+	assert.Nil(t, ExportTransactions(writer, overlapping))
+	assert.Equal(t, "amount\n100.00\n250.50\n", out.String())
+}
+
+//Balances Tests
+type mockBalanceChecker struct {
+	mu       sync.Mutex
+	balances map[Currency]float64
+	running  int
+	peak     int
+}
+
+func (m *mockBalanceChecker) CheckBalance(currency Currency) (CheckBalanceResult, error) {
+	m.mu.Lock()
+	m.running++
+	if m.running > m.peak {
+		m.peak = m.running
+	}
+	m.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running--
+	balance, ok := m.balances[currency]
+	if !ok {
+		return CheckBalanceResult{}, errors.New("wallet not found")
+	}
+	return CheckBalanceResult{WalletBalance: balance, WalletCurrency: string(currency)}, nil
+}
+
+func TestSelf_Balances(t *testing.T) {
+	balances, err := client.Self.Balances(context.Background(), CurrencyNigeria)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(balances))
+	assert.Equal(t, 880.16, balances[CurrencyNigeria].Balance)
+}
+
+func TestFetchBalances(t *testing.T) {
+	checker := &mockBalanceChecker{balances: map[Currency]float64{CurrencyNigeria: 41500, CurrencyUSA: 100, CurrencyGhana: 50}}
+	balances, err := FetchBalances(context.Background(), checker, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(balances))
+	assert.Equal(t, 2, checker.peak)
+	assert.NotNil(t, balances[CurrencyKenya].Err)
+	assert.Equal(t, []Currency{CurrencyGhana, CurrencyKenya, CurrencyNigeria, CurrencyUSA}, balances.Currencies())
+
+	value, err := balances.Convert(StaticFXRates{"USD/NGN": 415}, CurrencyNigeria)
+	assert.Nil(t, err)
+	assert.Equal(t, 83000.0, value.Total)
+	assert.Equal(t, 41500.0, value.Converted[CurrencyUSA])
+	assert.Equal(t, 2, len(value.Errors))
+
+	value, _ = balances.Convert(StaticFXRates{"USD/NGN": 415}, CurrencyUSA)
+	assert.Equal(t, 200.0, value.Total)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	balances, err = FetchBalances(ctx, checker, 1, CurrencyNigeria, CurrencyUSA)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, balances[CurrencyUSA].Err)
+}
+
+//Monitor Tests
+func TestBalanceMonitor_Check(t *testing.T) {
+	checker := &mockBalanceChecker{balances: map[Currency]float64{CurrencyNigeria: 1000}}
+	alerts := []BalanceAlert{}
+	monitor := NewBalanceMonitor(checker, time.Millisecond, map[Currency]BalanceThreshold{
+		CurrencyNigeria: {Low: 500, Recover: 800},
+		CurrencyKenya:   {Low: 10},
+	})
+	monitor.OnAlert = func(alert BalanceAlert) { alerts = append(alerts, alert) }
+	failures := 0
+	monitor.OnError = func(currency Currency, err error) { failures++ }
+
+	for _, balance := range []float64{1000, 400, 300, 700, 900, 950} {
+		checker.balances[CurrencyNigeria] = balance
+		monitor.Check(context.Background())
+	}
+
+	assert.Equal(t, 2, len(alerts))
+	assert.Equal(t, BalanceAlertLow, alerts[0].Kind)
+	assert.Equal(t, 400.0, alerts[0].Balance)
+	assert.Equal(t, BalanceAlertRecovered, alerts[1].Kind)
+	assert.Equal(t, 800.0, alerts[1].Threshold)
+	assert.False(t, monitor.IsLow(CurrencyNigeria))
+	assert.Equal(t, 6, failures)
+}
+
+func TestBalanceMonitor_Run(t *testing.T) {
+	alerts := make(chan BalanceAlert)
+	monitor := NewBalanceMonitor(client.Self, time.Millisecond, map[Currency]BalanceThreshold{CurrencyNigeria: {Low: 1000}})
+	monitor.Alerts = alerts
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- monitor.Run(ctx) }()
+
+	alert := <-alerts
+	assert.Equal(t, BalanceAlertLow, alert.Kind)
+	assert.Equal(t, 880.16, alert.Balance)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	monitor.Thresholds[CurrencyNigeria] = BalanceThreshold{Low: 1000, Recover: 900}
+	assert.NotNil(t, monitor.Run(context.Background()))
+}
+
+//Payout Status Tests
+type mockBankDetailSource struct {
+	details []BankDetail
+	calls   int
+	err     error
+}
+
+func (m *mockBankDetailSource) BankDetails(transactionReference string) (BankDetail, error) {
+	m.calls++
+	if m.err != nil {
+		return BankDetail{}, m.err
+	}
+	if m.calls == 1 {
+		return BankDetail{}, errors.New("Request Failed - Error Code: 500")
+	}
+	detail := m.details[0]
+	if len(m.details) > 1 {
+		m.details = m.details[1:]
+	}
+	return detail, nil
+}
+
+func TestBankDetail_Status(t *testing.T) {
+	assert.Equal(t, PayoutStatusSuccessful, BankDetail{ResponseCode: "200"}.Status())
+	assert.Equal(t, PayoutStatusPending, BankDetail{ResponseCode: "09"}.Status())
+	assert.Equal(t, PayoutStatusUnknown, BankDetail{ResponseCode: "X1"}.Status())
+	assert.Equal(t, PayoutStatusFailed, BankDetail{ResponseCode: "51"}.Status())
+	assert.Equal(t, PayoutStatusReversed, BankDetail{ResponseCode: "200", Message: "Transaction Reversed"}.Status())
+	assert.Equal(t, PayoutStatusReversed, BankDetail{ResponseCode: "00", Message: "Reversal completed"}.Status())
+	for _, message := range []string{"Transfer is irreversible", "This transfer is not reversible", "Transaction has not been reversed"} {
+		assert.Equal(t, PayoutStatusSuccessful, BankDetail{ResponseCode: "200", Message: message}.Status(), message)
+	}
+	assert.False(t, PayoutStatusPending.Terminal())
+	assert.True(t, PayoutStatusReversed.Terminal())
+}
+
+func TestPayouts_WaitForPayout(t *testing.T) {
+	detail, status, err := client.Payouts.WaitForPayout(context.Background(), "2578615312")
+	assert.Nil(t, err)
+	assert.Equal(t, PayoutStatusSuccessful, status)
+	assert.Equal(t, "Gtbank Plc", detail.Bank)
+
+	options := PayoutPollOptions{InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+	source := &mockBankDetailSource{details: []BankDetail{{ResponseCode: "09"}, {ResponseCode: "09"}, {ResponseCode: "51", Message: "Insufficient Funds"}}}
+	detail, status, err = WaitForPayout(context.Background(), source, "REF", options)
+	assert.Nil(t, err)
+	assert.Equal(t, PayoutStatusFailed, status)
+	assert.Equal(t, 4, source.calls)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	source = &mockBankDetailSource{details: []BankDetail{{ResponseCode: "09"}}}
+	_, status, err = WaitForPayout(ctx, source, "REF", options)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, PayoutStatusPending, status)
+
+	//Test Stop Conditions
+	source = &mockBankDetailSource{err: errors.New("Request Failed - Error Code: 401 | Message: Invalid secret key")}
+	_, _, err = WaitForPayout(context.Background(), source, "REF", options)
+	assert.Equal(t, 1, source.calls, "client errors are not retried")
+	assert.EqualError(t, err, "waiting for payout REF stopped after 1 lookups - Request Failed - Error Code: 401 | Message: Invalid secret key")
+
+	source = &mockBankDetailSource{err: errors.New("Request Failed - Error Code: 503 | Message: Unavailable")}
+	options.MaxAttempts = 3
+	_, _, err = WaitForPayout(context.Background(), source, "REF", options)
+	assert.Equal(t, 3, source.calls)
+	waitErr, ok := err.(*PayoutWaitError)
+	assert.True(t, ok)
+	assert.EqualError(t, waitErr.LastLookupError, "Request Failed - Error Code: 503 | Message: Unavailable")
+	assert.Contains(t, err.Error(), "last lookup error: Request Failed - Error Code: 503")
+
+	source = &mockBankDetailSource{details: []BankDetail{{ResponseCode: "X1"}}}
+	_, status, err = WaitForPayout(context.Background(), source, "REF", options)
+	assert.Equal(t, PayoutStatusUnknown, status)
+	assert.Equal(t, 2, source.calls)
+	assert.Contains(t, err.Error(), `unrecognised response code "X1"`)
+}
+
+//StartServer initializes a test HTTP server useful for request mocking, Integration tests and Client configuration
+//Payout Batch Tests
+type mockPayoutService struct {
+	mu        sync.Mutex
+	sent      []string
+	fails     map[string]bool
+	timeouts  map[string]bool
+	bankCalls int
+}
+
+func (m *mockPayoutService) GetBanks() (Banks, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bankCalls++
+	return Banks{{BankCode: "044", BankName: "Access Bank Nigeria", BankSortCode: "000014"}}, nil
+}
+
+func (m *mockPayoutService) BankTransfer(amount float64, bankCode, accountNumber, accountName, narration, transactionReference string) (BankTransferResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, transactionReference)
+	if m.fails[transactionReference] {
+		return BankTransferResult{ResponseCode: "51", Message: "Insufficient Funds"}, errors.New("Request Failed - Error Code: 51 | Message: Insufficient Funds")
+	}
+	if m.timeouts[transactionReference] {
+		return BankTransferResult{}, errors.New("Post https://sandbox.wallets.africa/transfer/bank/account: net/http: request canceled (Client.Timeout exceeded while awaiting headers)")
+	}
+	return BankTransferResult{TransactionReference: transactionReference, ResponseCode: "200"}, nil
+}
+
+func TestReadPayoutInstructionsCSV(t *testing.T) {
+	input := "\ufeffReference,Bank_Code,Account_Number,Account_Name,Amount,Narration\nPAY-1,044,0690000031,John Doe,1500.50,Salary\n"
+	instructions, err := ReadPayoutInstructionsCSV(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, []PayoutInstruction{{Reference: "PAY-1", BankCode: "044", AccountNumber: "0690000031", AccountName: "John Doe", Amount: 1500.50, Narration: "Salary"}}, instructions)
+
+	_, err = ReadPayoutInstructionsCSV(strings.NewReader("reference,bank_code\nPAY-1,044\n"))
+	assert.NotNil(t, err)
+
+	_, err = ReadPayoutInstructionsCSV(strings.NewReader("reference,bank_code,account_number,account_name,amount\nPAY-1,044,0690000031,John,ten\n"))
+	assert.NotNil(t, err)
+}
+
+func TestPayoutBatch_Run(t *testing.T) {
+	instructions := []PayoutInstruction{
+		{Reference: "PAY-1", BankCode: "044", AccountNumber: "0690000032", AccountName: "John Doe", Amount: 100},
+		{Reference: "PAY-2", BankCode: "044", AccountNumber: "0690000049", AccountName: "Jane Doe", Amount: 200},
+		{Reference: "PAY-3", BankCode: "999", AccountNumber: "0690000033", AccountName: "Jim Doe", Amount: 300},
+		{Reference: "PAY-4", BankCode: "044", AccountNumber: "06900", AccountName: "Jill Doe", Amount: 400},
+		{Reference: "PAY-5", BankCode: "044", AccountNumber: "0690000056", AccountName: "Jack Doe", Amount: 500},
+		{Reference: "PAY-5", BankCode: "044", AccountNumber: "0690000063", AccountName: "Jen Doe", Amount: 600},
+		{Reference: "PAY-6", BankCode: "044", AccountNumber: "0690000031", AccountName: "Joy Doe", Amount: 700},
+	}
+
+	service := &mockPayoutService{fails: map[string]bool{"PAY-2": true}}
+	store := NewMemoryBatchStore()
+	batch := &PayoutBatch{ID: "payroll-2020-04", Payouts: service, Store: store, Concurrency: 2, RatePerSecond: 1000, ValidateAccountNumbers: true}
+
+	summary, err := batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, summary.Total)
+	assert.Equal(t, 1, summary.Succeeded)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 5, summary.Invalid)
+	assert.Equal(t, 100.0, summary.AmountSucceeded)
+	assert.Equal(t, 200.0, summary.AmountFailed)
+	assert.Equal(t, 2, len(service.sent))
+
+	//Resuming sends nothing new; retrying failed items only resends PAY-2
+	summary, err = batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Skipped)
+	assert.Equal(t, 2, len(service.sent))
+
+	service.fails = nil
+	batch.RetryFailed = true
+	summary, err = batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, 2, summary.Succeeded)
+	sort.Strings(service.sent)
+	assert.Equal(t, []string{"PAY-1", "PAY-2", "PAY-2"}, service.sent)
+
+	report := bytes.Buffer{}
+	assert.Nil(t, summary.WriteCSV(&report))
+	assert.Contains(t, report.String(), "PAY-3,999,0690000033,Jim Doe,300.00,invalid,unknown bank code")
+	assert.Contains(t, report.String(), "PAY-6,044,0690000031,Joy Doe,700.00,invalid,account number 0690000031 is not valid for Access Bank Nigeria")
+
+	//Without ValidateAccountNumbers only the format is checked, as for banks whose API code is not their NUBAN code
+	batch.ValidateAccountNumbers = false
+	valid, invalid, err := batch.Validate(instructions[6:])
+	assert.Nil(t, err)
+	assert.Len(t, valid, 1)
+	assert.Empty(t, invalid)
+	_, invalid, _ = batch.Validate(instructions[3:4])
+	assert.Equal(t, "account number 06900 is not 10 digits", invalid[0].Error)
+}
+
+func TestFileBatchStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "payout-batch")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &FileBatchStore{Dir: dir}
+	results, err := store.LoadResults("run-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(results))
+
+	instruction := PayoutInstruction{Reference: "PAY-1", Amount: 100}
+	assert.Nil(t, store.SaveResult("run-1", PayoutItemResult{Instruction: instruction, Status: BatchItemFailed}))
+	assert.Nil(t, store.SaveResult("run-1", PayoutItemResult{Instruction: instruction, Status: BatchItemSucceeded}))
+
+	results, err = store.LoadResults("run-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, BatchItemSucceeded, results[0].Status)
+}
+
+//Credit Batch Tests
+type mockWalletCreditor struct {
+	mu       sync.Mutex
+	credited []string
+}
+
+func (m *mockWalletCreditor) Credit(amount float64, transactionReference, phoneNumber string) (CreditWalletResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if phoneNumber == "08000000000" {
+		return CreditWalletResult{}, errors.New("Request Failed - Error Code: 400 | Message: Wallet not found")
+	}
+	if phoneNumber == "08000000001" {
+		return CreditWalletResult{}, errors.New("Post https://sandbox.wallets.africa/wallet/credit: net/http: request canceled (Client.Timeout exceeded while awaiting headers)")
+	}
+	m.credited = append(m.credited, transactionReference)
+	return CreditWalletResult{AmountCredited: amount, RecipientWalletBalance: amount + 50}, nil
+}
+
+func TestReadCreditInstructions(t *testing.T) {
+	instructions, err := ReadCreditInstructionsCSV(strings.NewReader("Phone_Number,Amount,Reference\n08112498539,1000,PROMO-1\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []CreditInstruction{{PhoneNumber: "08112498539", Amount: 1000, Reference: "PROMO-1"}}, instructions)
+
+	instructions, err = ReadCreditInstructionsJSON(strings.NewReader(`[{"phone_number": "08112498539", "amount": 250.5, "reference": "REFUND-1"}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, []CreditInstruction{{PhoneNumber: "08112498539", Amount: 250.5, Reference: "REFUND-1"}}, instructions)
+
+	_, err = ReadCreditInstructionsCSV(strings.NewReader("phone_number,amount\n08112498539,1000\n"))
+	assert.NotNil(t, err)
+}
+
+func TestCreditBatch_Run(t *testing.T) {
+	instructions := []CreditInstruction{
+		{PhoneNumber: "08112498539", Amount: 1000, Reference: "PROMO-1"},
+		{PhoneNumber: "08000000000", Amount: 500, Reference: "PROMO-2"},
+		{PhoneNumber: "0811", Amount: 500, Reference: "PROMO-3"},
+		{PhoneNumber: "08112498540", Amount: 10.005, Reference: "PROMO-4"},
+		{PhoneNumber: "08112498541", Amount: 5000, Reference: "PROMO-5"},
+		{PhoneNumber: "08112498542", Amount: 100, Reference: "PROMO-6"},
+		{PhoneNumber: "08112498543", Amount: 100, Reference: "PROMO-6"},
+	}
+
+	creditor := &mockWalletCreditor{}
+	batch := &CreditBatch{Wallets: creditor, Concurrency: 2, MaxAmount: 2000, DryRun: true}
+
+	summary, err := batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Validated)
+	assert.Equal(t, 5, summary.Invalid)
+	assert.Equal(t, 0, len(creditor.credited))
+	assert.Equal(t, "invalid phone number 0811", summary.Results[2].Error)
+	assert.Equal(t, "amount has more than 2 decimal places", summary.Results[3].Error)
+	assert.Equal(t, "amount is above the batch limit of 2000.00", summary.Results[4].Error)
+
+	//Wallet numbers from /wallet/generate and /self/users are not always mobile numbers
+	summary, err = batch.Run(context.Background(), []CreditInstruction{{PhoneNumber: "13267006065", Amount: 100, Reference: "PROMO-7"}, {PhoneNumber: "10706391833", Amount: 100, Reference: "PROMO-8"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Validated)
+
+	batch.DryRun = false
+	summary, err = batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Succeeded)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 1000.0, summary.AmountCredited)
+	assert.Equal(t, 500.0, summary.AmountFailed)
+	assert.Equal(t, []string{"PROMO-1"}, creditor.credited)
+	assert.Equal(t, BatchItemSucceeded, summary.Results[0].Status)
+	assert.Equal(t, 1050.0, summary.Results[0].RecipientWalletBalance)
+
+	report := bytes.Buffer{}
+	assert.Nil(t, summary.WriteCSV(&report))
+	assert.Equal(t, 8, strings.Count(report.String(), "\n"))
+	assert.Contains(t, report.String(), "08000000000,500.00,PROMO-2,failed,Request Failed - Error Code: 400 | Message: Wallet not found")
+}
+
+func TestCreditBatch_Repeats(t *testing.T) {
+	instructions := []CreditInstruction{
+		{PhoneNumber: "08112498539", Amount: 1000, Reference: "PROMO-1"},
+		{PhoneNumber: "+2348112498539", Amount: 1000, Reference: "PROMO-2"},
+		{PhoneNumber: "08112498539", Amount: 500, Reference: "PROMO-3"},
+	}
+
+	batch := &CreditBatch{Wallets: &mockWalletCreditor{}, DryRun: true}
+	summary, err := batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Invalid)
+	assert.Equal(t, "08112498539 is credited 1000.00 more than once in the batch", summary.Results[0].Error)
+	assert.Equal(t, BatchItemValidated, summary.Results[2].Status)
+
+	batch.AllowRepeats = true
+	summary, err = batch.Run(context.Background(), instructions)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, summary.Validated)
+}
+
+//Provisioning Tests
+type mockWalletGenerator struct {
+	mu      sync.Mutex
+	created []string
+}
+
+func (m *mockWalletGenerator) Generate(currency Currency, firstName, lastName, email, dateOfBirth string) (Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if email == "fail@example.com" {
+		return Wallet{}, errors.New("Request Failed - Error Code: 400 | Message: Email already in use")
+	}
+	m.created = append(m.created, email)
+	return Wallet{Email: email, AccountNo: "9915937003", AccountName: firstName + " " + lastName, Bank: "Providus Bank", Password: "hacrenrgovhs66fwnfm4"}, nil
+}
+
+func TestProvisioner_Run(t *testing.T) {
+	input := `first_name,last_name,email,date_of_birth,currency
+John,Doe,johndoe@example.com,1992-10-03,
+Bruce,Wayne,BruceWayne@wayneenterprises.com,,NGN
+Fail,User,fail@example.com,,ngn
+No,Email,not-an-email,,
+Bad,Date,baddate@example.com,03/10/1992,
+Bad,Currency,badcurrency@example.com,,XYZ
+Twice,One,twice@example.com,,
+Twice,Two,twice@example.com,,
+`
+	requests, err := ReadWalletRequestsCSV(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, CurrencyNigeria, requests[0].Currency)
+
+	generator := &mockWalletGenerator{}
+	provisioner := &Provisioner{Wallets: generator, Existing: client.Self, Concurrency: 2}
+	summary, err := provisioner.Run(context.Background(), requests)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Created)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 5, summary.Invalid)
+	assert.Equal(t, []string{"johndoe@example.com"}, generator.created)
+	assert.Equal(t, "9915937003", summary.Results[0].AccountNo)
+
+	report := bytes.Buffer{}
+	assert.Nil(t, summary.WriteCSV(&report))
+	assert.Nil(t, summary.WriteJSON(&report))
+	assert.NotContains(t, report.String(), "hacrenrgovhs66fwnfm4")
+
+	dir, err := ioutil.TempDir("", "provisioning")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := dir + "/passwords.csv"
+	assert.Nil(t, summary.WritePasswordFile(path))
+	assert.NotNil(t, summary.WritePasswordFile(path))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	passwords, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "email,account_no,password\njohndoe@example.com,9915937003,hacrenrgovhs66fwnfm4\n", string(passwords))
+}
+
+//Bank Directory Tests
+type mockBankLister struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+	delay time.Duration
+}
+
+func (m *mockBankLister) GetBanks() (Banks, error) {
+	time.Sleep(m.delay)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return Banks{
+		{BankCode: "044", BankName: "Access Bank Nigeria", BankSortCode: "000014"},
+		{BankCode: "058", BankName: "Guaranty Trust Bank Plc", BankSortCode: "000013"},
+		{BankCode: "033", BankName: "United Bank for Africa", BankSortCode: "000004"},
+		{BankCode: "214", BankName: "First City Monument Bank", BankSortCode: "000003"},
+		{BankCode: "011", BankName: "First Bank of Nigeria", BankSortCode: "000016"},
+	}, nil
+}
+
+func (m *mockBankLister) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+func TestNormalizeBankName(t *testing.T) {
+	assert.Equal(t, "access", NormalizeBankName("Access Bank Nigeria Plc."))
+	assert.Equal(t, "guaranty trust", NormalizeBankName("GUARANTY TRUST BANK PLC"))
+	assert.Equal(t, "bank", NormalizeBankName("Bank"))
+}
+
+func TestBankDirectory_Lookup(t *testing.T) {
+	source := &mockBankLister{}
+	directory := NewBankDirectory(source, time.Hour, "")
+
+	bank, ok, err := directory.ByCode("058")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "Guaranty Trust Bank Plc", bank.BankName)
+
+	bank, ok, _ = directory.BySortCode("000004")
+	assert.True(t, ok)
+	assert.Equal(t, "033", bank.BankCode)
+
+	bank, ok, _ = directory.ByName("GTB")
+	assert.True(t, ok)
+	assert.Equal(t, "058", bank.BankCode)
+
+	bank, ok, _ = directory.ByName("access bank")
+	assert.True(t, ok)
+	assert.Equal(t, "044", bank.BankCode)
+
+	_, ok, err = directory.ByCode("999")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, source.Calls())
+
+	for query, code := range map[string]string{"GTB": "058", "Guaranty": "058", "guarantee trust": "058", "UBA": "033", "FCMB": "214", "fbn": "011", "000014": "044"} {
+		matches, err := directory.Search(query, 1)
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(matches), query) {
+			assert.Equal(t, code, matches[0].Bank.BankCode, query)
+		}
+	}
+
+	matches, _ := directory.Search("first", 0)
+	assert.Equal(t, 2, len(matches))
+
+	matches, _ = directory.Search("zzzz", 0)
+	assert.Equal(t, 0, len(matches))
+
+	for _, query := range []string{"", "   ", "--", "?!"} {
+		matches, _ = directory.Search(query, 0)
+		assert.Equal(t, 0, len(matches), "%q matches nothing", query)
+	}
+
+	//Source errors reach the caller instead of looking like an unknown bank
+	down := NewBankDirectory(&mockBankLister{err: errors.New("Request Failed - Error Code: 503 | Message: 503 Service Unavailable")}, time.Hour, "")
+	_, ok, err = down.ByCode("058")
+	assert.False(t, ok)
+	assert.EqualError(t, err, "Request Failed - Error Code: 503 | Message: 503 Service Unavailable")
+	assert.EqualError(t, down.ValidateAccount("058", "0123456789"), "Request Failed - Error Code: 503 | Message: 503 Service Unavailable")
+}
+
+func TestBankDirectory_ColdStart(t *testing.T) {
+	source := &mockBankLister{delay: 20 * time.Millisecond}
+	directory := NewBankDirectory(source, time.Hour, "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := directory.ByCode("044")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, source.Calls(), "concurrent cold starts share one fetch")
+}
+
+func TestBankDirectory_Refresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bank-directory")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := dir + "/banks.json"
+	source := &mockBankLister{}
+	directory := NewBankDirectory(source, time.Hour, path)
+	banks, err := directory.Banks()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(banks))
+
+	//A cold start is served from the snapshot even when the source is down
+	down := &mockBankLister{err: errors.New("Request Failed - Error Code: 503 | Message: 503 Service Unavailable")}
+	directory = NewBankDirectory(down, time.Hour, path)
+	banks, err = directory.Banks()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(banks))
+	assert.Equal(t, 0, down.Calls())
+
+	//A stale list is served while it is refreshed in the background
+	directory = NewBankDirectory(source, time.Nanosecond, path)
+	banks, err = directory.Banks()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(banks))
+	assert.Eventually(t, func() bool { return source.Calls() == 2 }, time.Second, time.Millisecond)
+
+	_, err = NewBankDirectory(down, time.Hour, "").Banks()
+	assert.NotNil(t, err)
+}
+
+//NUBAN Tests
+func TestNUBANCheckDigit(t *testing.T) {
+	//Worked example from the CBN NUBAN specification
+	digit, err := NUBANCheckDigit("011", "000001457")
+	assert.Nil(t, err)
+	assert.Equal(t, 9, digit)
+
+	digit, _ = NUBANCheckDigit("000011", "000001457")
+	assert.Equal(t, 9, digit)
+
+	_, err = NUBANCheckDigit("11", "000001457")
+	assert.NotNil(t, err)
+	_, err = NUBANCheckDigit("011", "00001457")
+	assert.NotNil(t, err)
+}
+
+func TestValidateNUBAN(t *testing.T) {
+	firstBank := Bank{BankCode: "011", BankName: "First Bank of Nigeria", BankSortCode: "000016"}
+	assert.Nil(t, ValidateNUBAN(firstBank, "0000014579"))
+	assert.Equal(t, "account number 0000014578 is not valid for First Bank of Nigeria", ValidateNUBAN(firstBank, "0000014578").Error())
+	assert.NotNil(t, ValidateNUBAN(firstBank, "00000145"))
+
+	//Banks without a numeric code only get the format check
+	assert.Nil(t, ValidateNUBAN(Bank{BankCode: "035A"}, "0000014578"))
+	assert.Equal(t, []string{"000044"}, NUBANCodes(Bank{BankCode: "044", BankSortCode: "000014"}))
+	assert.Equal(t, []string{}, NUBANCodes(Bank{BankCode: "035A", BankSortCode: "000014"}))
+
+	banks, _ := (&mockBankLister{}).GetBanks()
+	suggestions := SuggestBanks(banks, "0000014579")
+	assert.Contains(t, suggestions, banks[4])
+	assert.NotContains(t, suggestions, banks[1])
+	assert.Equal(t, Banks{}, SuggestBanks(banks, "12345"))
+
+	directory := NewBankDirectory(&mockBankLister{}, time.Hour, "")
+	assert.Nil(t, directory.ValidateAccount("044", "0690000032"))
+	assert.NotNil(t, directory.ValidateAccount("044", "0690000031"))
+	assert.NotNil(t, directory.ValidateAccount("999", "0690000032"))
+}
+
+//Identity Match Tests
+func TestMatchIdentity(t *testing.T) {
+	resolved, _ := client.Identity.ResolveBVN("22231485915")
+
+	match := MatchIdentity(resolved, IdentityClaim{FirstName: "John", LastName: "Doe", DateOfBirth: "1992-04-11", PhoneNumber: "+234 706 657 415"})
+	assert.True(t, match.Matched)
+	assert.Equal(t, 1.0, match.Score)
+	assert.Equal(t, "exact match", match.Field(MatchFieldFirstName).Reason)
+	assert.Equal(t, "same date", match.Field(MatchFieldDateOfBirth).Reason)
+	assert.False(t, match.Field(MatchFieldMiddleName).Compared)
+
+	//Swapped names still match, with a reason for the reviewer
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Doe", LastName: "John"})
+	assert.True(t, match.Matched)
+	assert.Equal(t, "matches another name on the BVN (DOE)", match.Field(MatchFieldFirstName).Reason)
+
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Jon", LastName: "Doe", DateOfBirth: "04/11/1992"})
+	assert.False(t, match.Matched)
+	assert.True(t, match.Field(MatchFieldFirstName).Matched)
+	assert.Contains(t, match.Field(MatchFieldFirstName).Reason, "similar spelling")
+	assert.Equal(t, "day and month swapped", match.Field(MatchFieldDateOfBirth).Reason)
+
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Jane", LastName: "Doe", DateOfBirth: "11-04-1992"})
+	assert.False(t, match.Matched)
+	assert.Equal(t, "does not match", match.Field(MatchFieldFirstName).Reason)
+
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "John", DateOfBirth: "11-04-1992"})
+	assert.False(t, match.Matched)
+
+	//Short names one letter apart are different names, not typos
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Joan", LastName: "Doe", DateOfBirth: "1992-04-11"})
+	assert.False(t, match.Matched)
+	assert.False(t, match.Field(MatchFieldFirstName).Matched)
+
+	match = MatchIdentity(ResolveBVN{FirstName: "ADA", LastName: "OBI"}, IdentityClaim{FirstName: "Ade", LastName: "Obi"})
+	assert.False(t, match.Matched)
+	assert.False(t, match.Field(MatchFieldFirstName).Matched)
+
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Jhon", LastName: "Doe"})
+	assert.True(t, match.Field(MatchFieldFirstName).Matched)
+
+	//One name on the BVN cannot satisfy both claimed names, and a swap only counts when both names swap
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Doe", LastName: "Doe", DateOfBirth: "1992-04-11"})
+	assert.False(t, match.Matched)
+	assert.False(t, match.Field(MatchFieldFirstName).Matched)
+
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "John", LastName: "John", DateOfBirth: "1992-04-11"})
+	assert.False(t, match.Matched)
+	assert.False(t, match.Field(MatchFieldLastName).Matched)
+
+	match = MatchIdentity(resolved, IdentityClaim{FirstName: "Doe", LastName: "John", DateOfBirth: "1992-04-11"})
+	assert.True(t, match.Matched)
+	assert.Equal(t, "matches another name on the BVN (DOE)", match.Field(MatchFieldFirstName).Reason)
+	assert.Equal(t, "matches another name on the BVN (JOHN)", match.Field(MatchFieldLastName).Reason)
+	assert.InDelta(t, 0.925, match.Score, 0.001, "a swap scores below the names as given")
 }
 
-func TestGetResponseCodeAndMessage(t *testing.T) {
-	r := responseBody{
-		"Response": map[string]interface{}{
-			"ResponseCode": "200",
-			"Message":      "Balance Retrieved successfully",
-		},
+func TestIdentityMatcher_Names(t *testing.T) {
+	resolved := ResolveBVN{FirstName: "OLUWASEUN", MiddleName: "ADÉBÁYỌ̀", LastName: "OKONKWO-ADEYEMI", DateOfBirth: "01-02-1990"}
+	matcher := &IdentityMatcher{}
+
+	match := matcher.Match(resolved, IdentityClaim{FirstName: "Oluwaseun", MiddleName: "Adebayo", LastName: "Adeyemi Okonkwo", DateOfBirth: "1 February 1990"})
+	assert.True(t, match.Matched)
+	assert.Equal(t, "matches ignoring case, accents and punctuation", match.Field(MatchFieldMiddleName).Reason)
+	assert.Equal(t, "matches ignoring case, accents and punctuation, words in a different order", match.Field(MatchFieldLastName).Reason)
+
+	match = matcher.Match(resolved, IdentityClaim{FirstName: "Oluwaseun", MiddleName: "A.", LastName: "Okonkwo-Adeyemi"})
+	assert.True(t, match.Matched)
+	assert.Equal(t, "matches by initial", match.Field(MatchFieldMiddleName).Reason)
+
+	assert.Equal(t, []string{"oneil", "adebayo"}, nameWords("O'Neil  Adébáyọ̀"))
+}
+
+//BVN Picture Tests
+func encodedTestPicture(t *testing.T, width, height int, format string) string {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
 	}
 
-	assert.Equal(t, "200", client.Self.getResponseCode(r))
-	assert.Equal(t, "Balance Retrieved successfully", client.Self.getResponseMessage(r))
+	raw := bytes.Buffer{}
+	if format == PictureFormatPNG {
+		assert.Nil(t, png.Encode(&raw, img))
+	} else {
+		assert.Nil(t, jpeg.Encode(&raw, img, nil))
+	}
+	return base64.StdEncoding.EncodeToString(raw.Bytes())
 }
 
-//Payouts Tests
-func TestPayouts_GetBanks(t *testing.T) {
-	banks, _ := client.Payouts.GetBanks()
-	assert.Equal(t, 2, len(banks))
-	assert.Equal(t, "044", banks[0].BankCode)
-	assert.Equal(t, "000017", banks[1].BankSortCode)
+func TestDecodeBVNPicture(t *testing.T) {
+	picture := encodedTestPicture(t, 120, 90, PictureFormatPNG)
+	img, format, err := ResolveBVN{Picture: picture}.DecodePicture()
+	assert.Nil(t, err)
+	assert.Equal(t, PictureFormatPNG, format)
+	assert.Equal(t, image.Rect(0, 0, 120, 90), img.Bounds())
+
+	_, format, err = DecodeBVNPicture("data:image/jpeg;base64,"+encodedTestPicture(t, 40, 40, PictureFormatJPEG), PictureOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, PictureFormatJPEG, format)
+
+	_, _, err = DecodeBVNPicture(picture, PictureOptions{MaxWidth: 100})
+	assert.Equal(t, "BVN picture is 120x90, larger than the 100x4096 limit", err.Error())
+
+	_, _, err = DecodeBVNPicture(picture, PictureOptions{MaxBytes: 64})
+	assert.Equal(t, "BVN picture is larger than 64 bytes", err.Error())
+
+	_, _, err = DecodeBVNPicture(base64.StdEncoding.EncodeToString([]byte("GIF89a")), PictureOptions{})
+	assert.Equal(t, "BVN picture is not a JPEG or PNG image", err.Error())
+
+	_, _, err = ResolveBVN{}.DecodePicture()
+	assert.NotNil(t, err)
+	_, _, err = DecodeBVNPicture("not base64!", PictureOptions{})
+	assert.NotNil(t, err)
 }
 
-func TestPayouts_BankDetails(t *testing.T) {
-	details, _ := client.Payouts.BankDetails("2578615312")
-	assert.Equal(t, "Gtbank Plc", details.Bank)
-	assert.Equal(t, "0200556677", details.AccountNumber)
-	assert.Equal(t, 10.00, details.Amount)
+func TestThumbnail(t *testing.T) {
+	img, _, _ := DecodeBVNPicture(encodedTestPicture(t, 120, 90, PictureFormatPNG), PictureOptions{})
+	assert.Equal(t, image.Rect(0, 0, 60, 45), Thumbnail(img, 60).Bounds())
+	assert.Equal(t, img, Thumbnail(img, 200))
+
+	out := bytes.Buffer{}
+	assert.Nil(t, WriteThumbnail(&out, img, 30))
+	thumbnail, err := jpeg.Decode(&out)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 30, 22), thumbnail.Bounds())
 }
 
-//Wallets Tests
-func TestWallets_Generate(t *testing.T) {
+//PII Tests
+func TestResolveBVN_Redact(t *testing.T) {
+	resolved, _ := client.Identity.ResolveBVN("22231485915")
+	resolved.Picture = "/9j/4AAQSkZJRgABAQ"
+
+	redacted := resolved.Redact()
+	assert.Equal(t, "*******5915", redacted.BVN)
+	assert.Equal(t, "J***", redacted.FirstName)
+	assert.Equal(t, "t***@example.com", redacted.Email)
+	assert.Equal(t, "*******415", redacted.PhoneNumber)
+	assert.Equal(t, "[redacted]", redacted.DateOfBirth)
+	assert.Equal(t, "[redacted]", redacted.Picture)
+	assert.Equal(t, "Access Bank", redacted.EnrollmentBank)
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		printed := fmt.Sprintf(format, resolved)
+		assert.NotContains(t, printed, "22231485915", format)
+		assert.NotContains(t, printed, "JOHN", format)
+		assert.NotContains(t, printed, "11-04-1992", format)
+	}
+
+	full, err := json.Marshal(resolved)
+	assert.Nil(t, err)
+	assert.Contains(t, string(full), `"BVN":"22231485915"`)
+
+	masked, err := resolved.MarshalJSONMode(PIIModeMasked)
+	assert.Nil(t, err)
+	assert.Contains(t, string(masked), `"BVN":"*******5915"`)
+
+	masked, _ = json.Marshal([]ResolveBVN{resolved.Redact()})
+	assert.NotContains(t, string(masked), "22231485915")
+
+	assert.Equal(t, "%!d(gowalletsafrica.ResolveBVN)", fmt.Sprintf("%d", resolved))
+	assert.Equal(t, "ResolveBVN{BVN", fmt.Sprintf("%.14s", resolved))
+	assert.Equal(t, fmt.Sprintf("%q", resolved.String()), fmt.Sprintf("%q", resolved))
+	assert.Equal(t, len(resolved.String())+5, len(fmt.Sprintf("%*v", len(resolved.String())+5, resolved)))
+}
+
+func TestWallet_Redact(t *testing.T) {
 	wallet, _ := client.Wallets.Generate(CurrencyNigeria, "John", "Doe", "johndoe@example.com", "1992-10-03")
 
-	assert.Equal(t, "johndoe@example.com", wallet.Email)
-	assert.Equal(t, "John", wallet.FirstName)
-	assert.Equal(t, "Doe", wallet.LastName)
-	assert.Equal(t, "1992-10-03", wallet.DateOfBirth)
+	printed := fmt.Sprintf("%+v", wallet)
+	assert.Equal(t, "Wallet{AccountNo: ******7003, AccountName: J*** D**, Bank: Providus Bank, FirstName: J***, LastName: D**, Email: j***@example.com, PhoneNumber: ********065, BVN: , DateOfBirth: [redacted], Password: [redacted]}", printed)
+
+	masked, _ := wallet.MarshalJSONMode(PIIModeMasked)
+	assert.NotContains(t, string(masked), "hacrenrgovhs66fwnfm4")
 }
 
-func TestWallets_Credit(t *testing.T) {
-	result, _ := client.Wallets.Credit(1000.0, "9821358010", "08112498539")
+func TestPIIEncryptor(t *testing.T) {
+	_, err := NewPIIEncryptor([]byte("short"))
+	assert.NotNil(t, err)
 
-	assert.Equal(t, 1000.0, result.AmountCredited)
-	assert.Equal(t, 1054.00, result.RecipientWalletBalance)
-	assert.Equal(t, 7305140.16, result.SenderWalletBalance)
+	encryptor, err := NewPIIEncryptor([]byte("0123456789abcdef0123456789abcdef"))
+	assert.Nil(t, err)
+
+	resolved, _ := client.Identity.ResolveBVN("22231485915")
+	encrypted, err := encryptor.EncryptResolveBVN(resolved)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(encrypted.BVN, "enc:v1:"))
+	assert.NotContains(t, encrypted.FirstName, "JOHN")
+	assert.Equal(t, "", encrypted.MiddleName)
+	assert.Equal(t, resolved.Gender, encrypted.Gender)
+
+	decrypted, err := encryptor.DecryptResolveBVN(encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, resolved, decrypted)
+
+	//Values are bound to their field and key
+	_, err = encryptor.DecryptField("PhoneNumber", encrypted.BVN)
+	assert.NotNil(t, err)
+
+	other, _ := NewPIIEncryptor([]byte("fedcba9876543210fedcba9876543210"))
+	_, err = other.DecryptResolveBVN(encrypted)
+	assert.NotNil(t, err)
+
+	_, err = encryptor.DecryptField("BVN", "22231485915")
+	assert.NotNil(t, err)
+}
+
+//BVN Cache Tests
+type mockBVNResolver struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	release chan struct{}
+}
+
+func (m *mockBVNResolver) ResolveBVN(bvn string) (ResolveBVN, error) {
+	if m.release != nil {
+		<-m.release
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+	m.calls[bvn]++
+	if bvn == "22299999999" {
+		panic("resolver failed")
+	}
+	if bvn == "22200000000" {
+		return ResolveBVN{}, errors.New("Request Failed - Error Code: 404 | Message: BVN not found")
+	}
+	return ResolveBVN{BVN: bvn, FirstName: "JOHN", LastName: "DOE"}, nil
+}
+
+func TestCachedBVNResolver(t *testing.T) {
+	encryptor, _ := NewPIIEncryptor([]byte("0123456789abcdef"))
+	resolver := &mockBVNResolver{}
+	cache, err := NewCachedBVNResolver(resolver, BVNCacheOptions{TTL: time.Minute, MaxEntries: 2, Encryptor: encryptor})
+	assert.Nil(t, err)
+	now := time.Date(2020, 4, 20, 9, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		result, err := cache.ResolveBVN("22231485915")
+		assert.Nil(t, err)
+		assert.Equal(t, "JOHN", result.FirstName)
+	}
+	assert.Equal(t, 1, resolver.calls["22231485915"])
+
+	//Cached values are held encrypted
+	entry := cache.entries[cache.key("22231485915")].Value.(*bvnCacheEntry)
+	assert.True(t, strings.HasPrefix(entry.result.BVN, "enc:v1:"))
+
+	//Errors are not cached
+	_, err = cache.ResolveBVN("22200000000")
+	assert.NotNil(t, err)
+	_, err = cache.ResolveBVN("22200000000")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, resolver.calls["22200000000"])
+
+	_, err = cache.ResolveBVN("123")
+	assert.NotNil(t, err)
+
+	//The least recently used entry is evicted
+	cache.ResolveBVN("22211111111")
+	cache.ResolveBVN("22231485915")
+	cache.ResolveBVN("22222222222")
+	cache.ResolveBVN("22231485915")
+	cache.ResolveBVN("22211111111")
+	assert.Equal(t, 1, resolver.calls["22231485915"])
+	assert.Equal(t, 2, resolver.calls["22211111111"])
+
+	now = now.Add(time.Minute)
+	cache.ResolveBVN("22231485915")
+	assert.Equal(t, 2, resolver.calls["22231485915"])
+
+	cache.Invalidate("22231485915")
+	cache.ResolveBVN("22231485915")
+	assert.Equal(t, 3, resolver.calls["22231485915"])
+
+	stats := cache.Stats()
+	assert.Equal(t, BVNCacheStats{Hits: 4, Misses: 8, Evictions: 2, Expirations: 1, Entries: 2}, stats)
+	assert.InDelta(t, 4.0/12.0, stats.HitRatio(), 0.0001)
+
+	cache.Purge()
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+func TestCachedBVNResolver_Concurrent(t *testing.T) {
+	resolver := &mockBVNResolver{release: make(chan struct{})}
+	cache, _ := NewCachedBVNResolver(resolver, BVNCacheOptions{})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := cache.ResolveBVN("22231485915")
+			assert.Nil(t, err)
+			assert.Equal(t, "DOE", result.LastName)
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return cache.Stats().Shared == 4 }, time.Second, time.Millisecond)
+	close(resolver.release)
+	wg.Wait()
+	assert.Equal(t, 1, resolver.calls["22231485915"])
+	assert.Equal(t, 0.8, cache.Stats().HitRatio())
+}
+
+func TestCachedBVNResolver_Panic(t *testing.T) {
+	resolver := &mockBVNResolver{release: make(chan struct{})}
+	cache, _ := NewCachedBVNResolver(resolver, BVNCacheOptions{})
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer func() { assert.Equal(t, "resolver failed", recover()) }()
+		cache.ResolveBVN("22299999999")
+	}()
+	assert.Eventually(t, func() bool { return cache.Stats().Misses == 1 }, time.Second, time.Millisecond)
+
+	//A lookup waiting on the one that panics gets an error instead of blocking forever
+	go func() {
+		defer wg.Done()
+		_, err := cache.ResolveBVN("22299999999")
+		assert.EqualError(t, err, "bvn cache - lookup did not complete")
+	}()
+	assert.Eventually(t, func() bool { return cache.Stats().Shared == 1 }, time.Second, time.Millisecond)
+
+	close(resolver.release)
+	wg.Wait()
+	assert.Equal(t, 0, cache.Stats().Entries)
+	assert.Empty(t, cache.inFlight)
+}
+
+func TestCachedBVNResolver_Key(t *testing.T) {
+	cache, _ := NewCachedBVNResolver(&mockBVNResolver{}, BVNCacheOptions{})
+	other, _ := NewCachedBVNResolver(&mockBVNResolver{}, BVNCacheOptions{})
+
+	sum := sha256.Sum256([]byte("22231485915"))
+	assert.NotEqual(t, hex.EncodeToString(sum[:]), cache.key("22231485915"))
+	assert.NotEqual(t, other.key("22231485915"), cache.key("22231485915"))
+	assert.Equal(t, cache.key("22231485915"), cache.key("22231485915"))
+}
+
+func TestCachedBVNResolver_InvalidateInFlight(t *testing.T) {
+	resolver := &mockBVNResolver{release: make(chan struct{})}
+	cache, _ := NewCachedBVNResolver(resolver, BVNCacheOptions{})
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cache.ResolveBVN("22231485915")
+	}()
+	assert.Eventually(t, func() bool { return cache.Stats().Misses == 1 }, time.Second, time.Millisecond)
+
+	//A lookup started after the invalidation does not share the stale one
+	cache.Invalidate("22231485915")
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cache.ResolveBVN("22231485915")
+	}()
+	assert.Eventually(t, func() bool { return cache.Stats().Misses == 2 }, time.Second, time.Millisecond)
+
+	close(resolver.release)
+	wg.Wait()
+	assert.Equal(t, 2, resolver.calls["22231485915"])
+	assert.Equal(t, 1, cache.Stats().Entries)
+
+	//Purge drops the results of lookups in flight too
+	resolver.release = make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cache.ResolveBVN("22211111111")
+	}()
+	assert.Eventually(t, func() bool { return cache.Stats().Misses == 3 }, time.Second, time.Millisecond)
+	cache.Purge()
+	close(resolver.release)
+	wg.Wait()
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+//KYC Policy Tests
+func TestKYCPolicy_Tier(t *testing.T) {
+	policy := NewDefaultKYCPolicy()
+	assert.Equal(t, KYCTier0, policy.Tier(ResolveBVN{}))
+	assert.Equal(t, KYCTier0, policy.Tier(ResolveBVN{BVN: "22231485915"}))
+	assert.Equal(t, KYCTier1, policy.Tier(ResolveBVN{BVN: "22231485915", LevelOfAccount: "Level 1 - Low Level Accounts"}))
+	assert.Equal(t, KYCTier2, policy.Tier(ResolveBVN{BVN: "22231485915", LevelOfAccount: "Level 2 - Medium Level Accounts"}))
+	assert.Equal(t, KYCTier3, policy.Tier(ResolveBVN{BVN: "22231485915", LevelOfAccount: "LEVEL3"}))
+	assert.Equal(t, KYCTier0, policy.Tier(ResolveBVN{BVN: "22231485915", LevelOfAccount: "Level 7"}))
+
+	//Every call returns its own policy
+	policy.Tiers[KYCTier0] = TierLimits{MaxBalance: Unlimited, MaxSingleCredit: Unlimited, DailyPayoutCap: Unlimited}
+	assert.Equal(t, 0.0, NewDefaultKYCPolicy().Tiers[KYCTier0].MaxSingleCredit)
+}
+
+func TestKYCPolicy_Evaluate(t *testing.T) {
+	policy := NewDefaultKYCPolicy()
+	resolved, _ := client.Identity.ResolveBVN("22231485915")
+
+	//The API returns no account level for this BVN, so nothing is allowed without a review
+	decision := policy.EvaluateCredit(resolved, 100, 0)
+	assert.Equal(t, KYCDeny, decision.Outcome)
+	assert.Equal(t, KYCTier0, decision.Tier)
+	assert.Equal(t, `account level "" could not be read, using tier 0`, decision.Reasons[0])
+
+	resolved.LevelOfAccount = "Level 1 - Low Level Accounts"
+	decision = policy.EvaluateCredit(resolved, 10000, 5000)
+	assert.True(t, decision.Allowed())
+	assert.Nil(t, decision.Err())
+	assert.Equal(t, KYCTier1, decision.Tier)
+
+	decision = policy.EvaluateCredit(resolved, 48000, 0)
+	assert.Equal(t, KYCReview, decision.Outcome)
+
+	decision = policy.EvaluateCredit(resolved, 20000, 290000)
+	assert.Equal(t, KYCDeny, decision.Outcome)
+	assert.Equal(t, "kyc deny (tier 1) - balance after credit of 310000.00 exceeds the tier 1 limit of 300000.00", decision.Err().Error())
+
+	assert.Equal(t, KYCAllow, policy.EvaluatePayout(resolved, 20000, 10000).Outcome)
+	assert.Equal(t, KYCDeny, policy.EvaluatePayout(resolved, 20000, 40000).Outcome)
+	assert.Equal(t, KYCDeny, policy.EvaluatePayout(ResolveBVN{}, 100, 0).Outcome)
+
+	tier3 := resolved
+	tier3.LevelOfAccount = "Level 3 - High Level Accounts"
+	assert.True(t, policy.EvaluateCredit(tier3, 1000000, 100000000).Allowed())
+
+	watchlisted := resolved
+	watchlisted.WatchListed = "YES"
+	decision = policy.EvaluateCredit(watchlisted, 100, 0)
+	assert.Equal(t, KYCDeny, decision.Outcome)
+	assert.Equal(t, []string{"BVN is watchlisted"}, decision.Reasons)
+
+	watchlisted.WatchListed = "pending"
+	assert.Equal(t, KYCReview, policy.EvaluateCredit(watchlisted, 100, 0).Outcome)
+
+	policy.Watchlist = map[string]bool{"22231485915": true}
+	assert.Equal(t, KYCDeny, policy.EvaluatePayout(resolved, 100, 0).Outcome)
+}
+
+//Limits Tests
+func TestLimiter_SlidingWindow(t *testing.T) {
+	now := time.Date(2020, 7, 18, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryCounterStore(0),
+		LimitRule{Name: "hourly", Operation: LimitOperationCredit, Window: time.Hour, MaxCount: 2},
+		LimitRule{Name: "daily", Operation: LimitOperationCredit, Window: 24 * time.Hour, MaxAmount: 1000},
+	)
+	limiter.now = func() time.Time { return now }
+
+	assert.Nil(t, limiter.Reserve(LimitOperationCredit, "2348030000001", "ref-1", 300))
+	assert.Nil(t, limiter.Reserve(LimitOperationCredit, "2348030000001", "ref-2", 300))
+	assert.Nil(t, limiter.Reserve(LimitOperationCredit, "2348030000002", "ref-3", 300), "counters are per subject")
+	assert.Nil(t, limiter.Reserve(LimitOperationPayout, "2348030000001", "ref-4", 5000), "rules are per operation")
+
+	err := limiter.Reserve(LimitOperationCredit, "2348030000001", "ref-5", 100)
+	limitErr, ok := err.(*LimitExceededError)
+	assert.True(t, ok)
+	assert.Equal(t, "hourly", limitErr.Rule.Name)
+	assert.Equal(t, 2, limitErr.Count)
+	assert.Equal(t, "limit hourly exceeded for 2348030000001 - 2 credit operations in 1h0m0s already, the limit is 2", err.Error())
+
+	now = now.Add(61 * time.Minute)
+	assert.Nil(t, limiter.Check(LimitOperationCredit, "2348030000001", 400))
+
+	err = limiter.Reserve(LimitOperationCredit, "2348030000001", "ref-6", 401)
+	limitErr, ok = err.(*LimitExceededError)
+	assert.True(t, ok)
+	assert.Equal(t, "daily", limitErr.Rule.Name)
+	assert.Equal(t, 600.0, limitErr.Sum)
+	assert.Equal(t, "limit daily exceeded for 2348030000001 - 401.00 would bring the credit total in 24h0m0s to 1001.00, the limit is 1000.00", err.Error())
+
+	assert.Nil(t, limiter.Release(LimitOperationCredit, "2348030000001", "ref-1"))
+	assert.Nil(t, limiter.Reserve(LimitOperationCredit, "2348030000001", "ref-6", 401))
+
+	now = now.Add(24 * time.Hour)
+	assert.Nil(t, limiter.Reserve(LimitOperationCredit, "2348030000001", "ref-7", 1000))
+}
+
+func TestLimitedWallets_Credit(t *testing.T) {
+	creditor := &mockWalletCreditor{}
+	wallets := &LimitedWallets{
+		Wallets: creditor,
+		Limiter: NewLimiter(NewMemoryCounterStore(0), LimitRule{Name: "hourly", Operation: LimitOperationCredit, Window: time.Hour, MaxCount: 2}),
+	}
+
+	_, err := wallets.Credit(100, "ref-1", "08030000001")
+	assert.Nil(t, err)
+	_, err = wallets.Credit(100, "ref-2", "+2348030000001")
+	assert.Nil(t, err)
+
+	_, err = wallets.Credit(100, "ref-3", "2348030000001")
+	assert.IsType(t, &LimitExceededError{}, err)
+	assert.Equal(t, []string{"ref-1", "ref-2"}, creditor.credited, "no API call once a limit is hit")
+
+	_, err = wallets.Credit(100, "ref-4", "08000000000")
+	assert.NotNil(t, err)
+	_, err = wallets.Credit(100, "ref-5", "08000000000")
+	assert.NotNil(t, err)
+	_, err = wallets.Credit(100, "ref-6", "08000000000")
+	assert.NotNil(t, err)
+	assert.IsType(t, errors.New(""), err, "rejected credits do not count towards the limits")
+
+	//A timeout may have credited the wallet, so it keeps counting
+	_, err = wallets.Credit(100, "ref-7", "08000000001")
+	assert.NotNil(t, err)
+	_, err = wallets.Credit(100, "ref-8", "08000000001")
+	assert.NotNil(t, err)
+	_, err = wallets.Credit(100, "ref-9", "08000000001")
+	assert.IsType(t, &LimitExceededError{}, err)
+
+	wallets.Limiter.Store = &failingCounterStore{CounterStore: NewMemoryCounterStore(0)}
+	_, err = wallets.Credit(100, "ref-10", "08000000000")
+	assert.Equal(t, "Request Failed - Error Code: 400 | Message: Wallet not found - limit reservation ref-10 could not be released - store unavailable", err.Error())
+
+	assert.True(t, rejectedByAPI(errors.New("Request Failed - Error Code: 51 | Message: Insufficient Funds")))
+	assert.False(t, rejectedByAPI(errors.New("Request Failed - Error Code: 502 | Message: Bad Gateway")))
+	assert.False(t, rejectedByAPI(errors.New("Request Failed - Error Code:  | Message: ")))
+}
+
+type failingCounterStore struct {
+	CounterStore
+}
+
+func (f *failingCounterStore) Cancel(key string, id string) error {
+	return errors.New("store unavailable")
+}
+
+func TestLimitedPayouts_BankTransfer(t *testing.T) {
+	service := &mockPayoutService{}
+	payouts := &LimitedPayouts{
+		Payouts: service,
+		Limiter: NewLimiter(NewMemoryCounterStore(0), LimitRule{Name: "daily", Operation: LimitOperationPayout, Window: 24 * time.Hour, MaxAmount: 5000}),
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	exceeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := payouts.BankTransfer(1000, "044", "0690000032", "Jane Doe", "Refund", fmt.Sprintf("ref-%v", i))
+			if _, ok := err.(*LimitExceededError); ok {
+				mu.Lock()
+				exceeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, exceeded)
+	assert.Len(t, service.sent, 5)
+
+	_, err := payouts.BankTransfer(1000, "044", "0690000049", "John Doe", "Refund", "ref-other")
+	assert.Nil(t, err, "counters are per account")
+
+	banks, err := payouts.GetBanks()
+	assert.Nil(t, err)
+	assert.Len(t, banks, 1)
+
+	_, err = payouts.BankTransfer(1000, "044", "0690000049", "John Doe", "Refund", "")
+	assert.EqualError(t, err, "transaction reference is required")
+	assert.EqualError(t, payouts.Limiter.Reserve(LimitOperationPayout, "044/0690000049", "", 1000), "limiter - reservation id is required")
+}
+
+func TestLimitedPayouts_NotSent(t *testing.T) {
+	payouts := &LimitedPayouts{
+		Payouts: client.Payouts,
+		Limiter: NewLimiter(NewMemoryCounterStore(0), LimitRule{Name: "daily", Operation: LimitOperationPayout, Window: 24 * time.Hour, MaxCount: 1}),
+	}
+
+	//A transfer that fails before it is sent does not use up the limits
+	_, err := payouts.BankTransfer(0, "044", "0690000032", "John Doe", "Salary", "SQ-ACME-PAY-0001")
+	assert.EqualError(t, err, "amount must be greater than 0")
+
+	_, err = payouts.BankTransfer(1000, "044", "0690000032", "John Doe", "Salary", "SQ-ACME-PAY-0003")
+	assert.Nil(t, err)
+	_, err = payouts.BankTransfer(1000, "044", "0690000032", "John Doe", "Salary", "SQ-ACME-PAY-0004")
+	assert.IsType(t, &LimitExceededError{}, err)
+}
+
+//Approvals Tests
+func TestApprovalPolicy_Required(t *testing.T) {
+	policy := ApprovalPolicy{Thresholds: []ApprovalThreshold{{Above: 100000, Approvals: 1}, {Above: 1000000, Approvals: 2}}}
+	assert.Equal(t, 0, policy.Required(100000))
+	assert.Equal(t, 1, policy.Required(100000.01))
+	assert.Equal(t, 2, policy.Required(5000000))
+}
+
+func TestApprovalWorkflow_Payout(t *testing.T) {
+	service := &mockPayoutService{}
+	now := time.Date(2020, 7, 18, 9, 0, 0, 0, time.UTC)
+	workflow := &ApprovalWorkflow{
+		Policy:  ApprovalPolicy{Thresholds: []ApprovalThreshold{{Above: 100000, Approvals: 2}}},
+		Store:   NewMemoryApprovalStore(),
+		Payouts: service,
+		now:     func() time.Time { return now },
+
+		ValidateAccountNumbers: true,
+	}
+
+	instruction := PayoutInstruction{Reference: "pay-1", BankCode: "044", AccountNumber: "0690000032", AccountName: "Jane Doe", Amount: 250000, Narration: "Invoice 42"}
+	request, err := workflow.SubmitPayout("ada", instruction)
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalPending, request.Status)
+	assert.Equal(t, 2, request.RequiredApprovals)
+	assert.Equal(t, now.Add(DefaultApprovalTTL), request.ExpiresAt)
+
+	_, err = workflow.SubmitPayout("ada", instruction)
+	assert.EqualError(t, err, "approval - reference pay-1 is already used")
+
+	_, err = workflow.Execute("pay-1", "ada")
+	assert.EqualError(t, err, "approval - request pay-1 is pending")
+
+	_, err = workflow.Approve("pay-1", "ADA", "")
+	assert.EqualError(t, err, "approval - ADA made request pay-1 and cannot approve it")
+
+	request, err = workflow.Approve("pay-1", "bola", "checked invoice")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalPending, request.Status)
+
+	_, err = workflow.Approve("pay-1", "bola", "")
+	assert.EqualError(t, err, "approval - bola already approved request pay-1")
+
+	pending, err := workflow.Pending()
+	assert.Nil(t, err)
+	assert.Len(t, pending, 1)
+
+	request, err = workflow.Approve("pay-1", "chidi", "")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalApproved, request.Status)
+	assert.Equal(t, []string{"bola", "chidi"}, request.Approvers())
+	assert.Empty(t, service.sent, "nothing is sent before Execute")
+
+	request, err = workflow.Execute("pay-1", "ada")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalExecuted, request.Status)
+	assert.True(t, request.Final())
+	assert.Equal(t, []string{"pay-1"}, service.sent)
+
+	_, err = workflow.Execute("pay-1", "ada")
+	assert.EqualError(t, err, "approval - request pay-1 is executed")
+	assert.Len(t, service.sent, 1)
+
+	trail, err := workflow.AuditTrail("pay-1")
+	assert.Nil(t, err)
+	actions := []string{}
+	for _, entry := range trail {
+		actions = append(actions, fmt.Sprintf("%v %v", entry.Actor, entry.Action))
+	}
+	assert.Equal(t, []string{"ada created", "bola approved", "chidi approved", "ada executing", "ada executed"}, actions)
+	assert.Equal(t, "payout of 250000.00 to 044/0690000032 (Jane Doe), 2 approvals required", trail[0].Detail)
+	assert.Equal(t, "1 of 2 approvals - checked invoice", trail[1].Detail)
+
+	_, err = workflow.SubmitPayout("ada", PayoutInstruction{Reference: "pay-2", BankCode: "044", AccountNumber: "0690000031", Amount: 100})
+	assert.NotNil(t, err)
+	_, err = workflow.SubmitPayout("ada", PayoutInstruction{Reference: "pay-2", BankCode: "999", AccountNumber: "0690000032", Amount: 100})
+	assert.EqualError(t, err, "unknown bank code 999")
+
+	workflow.ValidateAccountNumbers = false
+	_, err = workflow.SubmitPayout("ada", PayoutInstruction{Reference: "pay-2", BankCode: "044", AccountNumber: "0690000031", Amount: 100})
+	assert.Nil(t, err, "only the format is checked unless ValidateAccountNumbers is set")
+	assert.Equal(t, 1, service.bankCalls, "the bank list is fetched once per workflow")
+}
+
+func TestApprovalWorkflow_Unknown(t *testing.T) {
+	service := &mockPayoutService{fails: map[string]bool{"pay-1": true}, timeouts: map[string]bool{"pay-2": true}}
+	workflow := &ApprovalWorkflow{Store: NewMemoryApprovalStore(), Payouts: service}
+
+	for _, reference := range []string{"pay-1", "pay-2"} {
+		_, err := workflow.SubmitPayout("ada", PayoutInstruction{Reference: reference, BankCode: "044", AccountNumber: "0690000032", AccountName: "Jane Doe", Amount: 500})
+		assert.Nil(t, err)
+	}
+
+	request, err := workflow.Execute("pay-1", "ada")
+	assert.NotNil(t, err)
+	assert.Equal(t, ApprovalFailed, request.Status, "the API rejected it, so no money moved")
+
+	//A timeout may have paid the account, so the request waits for someone to check with the bank
+	request, err = workflow.Execute("pay-2", "ada")
+	assert.NotNil(t, err)
+	assert.Equal(t, ApprovalUnknown, request.Status)
+	assert.False(t, request.Final())
+	_, err = workflow.Execute("pay-2", "ada")
+	assert.EqualError(t, err, "approval - request pay-2 is unknown")
+
+	_, err = workflow.Resolve("pay-1", "bola", true, "")
+	assert.EqualError(t, err, "approval - request pay-1 is failed")
+
+	request, err = workflow.Resolve("pay-2", "bola", true, "on the bank statement")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalExecuted, request.Status)
+	assert.Equal(t, []string{"pay-1", "pay-2"}, service.sent)
+
+	trail, _ := workflow.AuditTrail("pay-2")
+	assert.Equal(t, AuditUnknown, trail[len(trail)-2].Action)
+	assert.Equal(t, "executed - on the bank statement", trail[len(trail)-1].Detail)
+}
+
+func TestApprovalWorkflow_StuckExecuting(t *testing.T) {
+	service := &mockPayoutService{}
+	store := NewMemoryApprovalStore()
+	now := time.Date(2020, 7, 18, 9, 0, 0, 0, time.UTC)
+	workflow := &ApprovalWorkflow{Store: store, Payouts: service, now: func() time.Time { return now }}
+
+	_, err := workflow.SubmitPayout("ada", PayoutInstruction{Reference: "pay-1", BankCode: "044", AccountNumber: "0690000032", AccountName: "Jane Doe", Amount: 500})
+	assert.Nil(t, err)
+
+	//A crash between marking the request and recording the outcome leaves it executing
+	request, _, _ := store.LoadRequest("pay-1")
+	request.Status = ApprovalExecuting
+	assert.Nil(t, store.SaveRequest(request))
+
+	_, err = workflow.Execute("pay-1", "bola")
+	assert.EqualError(t, err, "approval - request pay-1 is executing")
+	_, err = workflow.Reject("pay-1", "bola", "")
+	assert.EqualError(t, err, "approval - request pay-1 is executing")
+	assert.Empty(t, service.sent, "the money may have moved, so it is never sent again")
+
+	now = now.Add(2 * DefaultApprovalTTL)
+	expired, err := workflow.Expire()
+	assert.Nil(t, err)
+	assert.Empty(t, expired)
+
+	request, err = workflow.Request("pay-1")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalExecuting, request.Status)
+	assert.False(t, request.Final(), "left for someone to check with the bank")
+
+	request, err = workflow.Resolve("pay-1", "bola", false, "not on the bank statement")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalFailed, request.Status)
+	assert.Empty(t, service.sent)
+}
+
+func TestApprovalWorkflow_Credit(t *testing.T) {
+	creditor := &mockWalletCreditor{}
+	now := time.Date(2020, 7, 18, 9, 0, 0, 0, time.UTC)
+	workflow := &ApprovalWorkflow{
+		Policy:  ApprovalPolicy{Thresholds: []ApprovalThreshold{{Above: 1000, Approvals: 1}}, TTL: time.Hour},
+		Store:   NewMemoryApprovalStore(),
+		Wallets: creditor,
+		now:     func() time.Time { return now },
+	}
+
+	request, err := workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "08030000001", Amount: 500, Reference: "credit-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalApproved, request.Status, "amounts below every threshold need no approval")
+
+	_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "08030000001", Amount: 5000, Reference: "credit-2"})
+	assert.Nil(t, err)
+	_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "08000000000", Amount: 5000, Reference: "credit-3"})
+	assert.Nil(t, err)
+	_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "0803", Amount: 5000, Reference: "credit-4"})
+	assert.NotNil(t, err)
+	_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "13267006065", Amount: 5000, Reference: "credit-4"})
+	assert.Nil(t, err, "wallets the API generates have numbers that are not mobile numbers")
+
+	_, err = workflow.Approve("credit-3", "bola", "")
+	assert.Nil(t, err)
+	request, err = workflow.Execute("credit-3", "bola")
+	assert.EqualError(t, err, "Request Failed - Error Code: 400 | Message: Wallet not found")
+	assert.Equal(t, ApprovalFailed, request.Status)
+	_, err = workflow.Execute("credit-3", "bola")
+	assert.EqualError(t, err, "approval - request credit-3 is failed", "failed requests are not retried")
+
+	request, err = workflow.Reject("credit-1", "bola", "duplicate")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalRejected, request.Status)
+
+	//Makers can withdraw their own requests
+	_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "08030000001", Amount: 5000, Reference: "credit-5"})
+	assert.Nil(t, err)
+	request, err = workflow.Reject("credit-5", "ada", "wrong amount")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalRejected, request.Status)
+	_, err = workflow.Approve("credit-5", "bola", "")
+	assert.EqualError(t, err, "approval - request credit-5 is rejected")
+	trail, _ := workflow.AuditTrail("credit-5")
+	assert.Equal(t, "ada", trail[len(trail)-1].Actor)
+
+	now = now.Add(time.Hour)
+	expired, err := workflow.Expire()
+	assert.Nil(t, err)
+	assert.Len(t, expired, 2)
+	assert.Equal(t, "credit-2", expired[0].ID)
+	assert.Equal(t, "credit-4", expired[1].ID)
+
+	_, err = workflow.Approve("credit-2", "bola", "")
+	assert.EqualError(t, err, "approval - request credit-2 is expired")
+	assert.Empty(t, creditor.credited)
+
+	trail, _ = workflow.AuditTrail("credit-2")
+	assert.Equal(t, AuditExpired, trail[len(trail)-1].Action)
+	assert.Equal(t, AuditActorSystem, trail[len(trail)-1].Actor)
+}
+
+func TestFileApprovalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approvals")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	workflow := &ApprovalWorkflow{
+		Policy: ApprovalPolicy{Thresholds: []ApprovalThreshold{{Above: 0, Approvals: 1}}},
+		Store:  &FileApprovalStore{Dir: dir},
+	}
+	_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "08030000001", Amount: 500, Reference: "credit-1"})
+	assert.Nil(t, err)
+	_, err = workflow.Approve("credit-1", "bola", "ok")
+	assert.Nil(t, err)
+
+	reopened := &ApprovalWorkflow{Store: &FileApprovalStore{Dir: dir}}
+	request, err := reopened.Request("credit-1")
+	assert.Nil(t, err)
+	assert.Equal(t, ApprovalApproved, request.Status)
+	assert.Equal(t, "08030000001", request.Credit.PhoneNumber)
+	assert.Equal(t, []string{"bola"}, request.Approvers())
+
+	trail, err := reopened.AuditTrail("credit-1")
+	assert.Nil(t, err)
+	assert.Len(t, trail, 2)
+
+	_, err = reopened.Request("missing")
+	assert.EqualError(t, err, "approval - request missing not found")
+
+	//IDs that share a base name or contain dots do not collide or leave the directory
+	for _, id := range []string{"a/x", "b/x", "../credit-1"} {
+		_, err = workflow.SubmitCredit("ada", CreditInstruction{PhoneNumber: "08030000001", Amount: 500, Reference: id})
+		assert.Nil(t, err, id)
+	}
+	request, err = reopened.Request("a/x")
+	assert.Nil(t, err)
+	assert.Equal(t, "a/x", request.ID)
+
+	requests, err := reopened.Store.ListRequests()
+	assert.Nil(t, err)
+	assert.Len(t, requests, 4)
+
+	store := &FileApprovalStore{Dir: dir}
+	raw, _ := ioutil.ReadFile(store.requestPath("a/x"))
+	assert.Nil(t, ioutil.WriteFile(store.requestPath("c"), raw, 0600))
+	_, _, err = store.LoadRequest("c")
+	assert.EqualError(t, err, "approval request c - file holds request a/x")
 }
 
-//StartServer initializes a test HTTP server useful for request mocking, Integration tests and Client configuration
 func MockAPIServer(t *testing.T) *httptest.Server {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-type", "application/json")
@@ -292,6 +2359,21 @@ func MockAPIServer(t *testing.T) *httptest.Server {
 			w.WriteHeader(200)
 			fmt.Fprintf(w, successBody)
 
+		case "/transfer/bank/account":
+			successBody := `{
+	"Response": {
+		"ResponseCode": "200",
+		"Message": "Transfer Successful"
+	},
+	"Data": {
+		"AmountCharged": 1010.75,
+		"RecipientName": "JOHN DOE",
+		"SessionId": "000014200415123456789012345678"
+	}
+}`
+			w.WriteHeader(200)
+			fmt.Fprintf(w, successBody)
+
 		case "/wallet/generate":
 			successBody := `{
   "Response": {